@@ -40,6 +40,38 @@ func getKubeConfig() (string, error) {
 	return kubeconfig, nil
 }
 
+// GetCurrentContext returns the name of the current kube context, the name of its cluster and its namespace
+//
+func GetCurrentContext() (string, string, string, error) {
+	kubeConfigPath, err := getKubeConfig()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{
+			ExplicitPath: kubeConfigPath,
+		},
+		&clientcmd.ConfigOverrides{},
+	)
+	rawConfig, err := clientConfig.RawConfig()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	context, found := rawConfig.Contexts[rawConfig.CurrentContext]
+	if !found {
+		return "", "", "", fmt.Errorf("Current kube context %s not found", rawConfig.CurrentContext)
+	}
+
+	ns, _, err := clientConfig.Namespace()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return rawConfig.CurrentContext, context.Cluster, ns, nil
+}
+
 // ConnectToCluster connects and returns the kubernetes client
 //
 func ConnectToCluster() (*kubernetes.Clientset, *apiextension.Clientset, *polarisv1alpha1.Clientset, string, error) {
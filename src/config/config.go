@@ -6,21 +6,197 @@ import (
 	"log"
 	"os"
 	"os/user"
+	"sort"
+	"strings"
+	"time"
 
 	yaml "gopkg.in/yaml.v2"
 )
 
-// PolarisRepository defines a particular Git repository plus branch
+// Version is the version of the polaris CLI
+//
+const Version = "0.0.3"
+
+// PolarisRepository defines a particular Git repository plus the branch it follows, or the tag or
+// commit it is pinned to. A local repository is instead a directory which is read in place.
+// TrustedKeys are the files of armored PGP public keys which must have signed what is checked out
 //
 type PolarisRepository struct {
-	Ref string
-	URI string
+	Ref          string
+	URI          string
+	Local        string                `yaml:",omitempty"`
+	Alias        string                `yaml:",omitempty"`
+	Priority     int                   `yaml:",omitempty"`
+	TrustedKeys  []string              `yaml:",omitempty"`
+	SyncInterval time.Duration         `yaml:",omitempty"`
+	Limits       PolarisRenderLimits   `yaml:",omitempty"`
+	Auth         PolarisRepositoryAuth `yaml:",omitempty"`
+}
+
+// PolarisRepositoryAuth defines how to authenticate to a private repository. Type is one of ssh-key,
+// ssh-agent, basic, token or netrc (or empty for none). Secrets are never kept in the config:
+// PasswordEnv names the environment variable holding the password, token or key passphrase
+//
+type PolarisRepositoryAuth struct {
+	Type        string `yaml:",omitempty"`
+	User        string `yaml:",omitempty"`
+	KeyPath     string `yaml:",omitempty"`
+	PasswordEnv string `yaml:",omitempty"`
+}
+
+// PolarisRepositoryManifest describes a repository, from the optional polaris-repo.yaml at its root.
+// Catalog lists the paths of its scaffolds in the order they are listed (the rest follow by name), and
+// MinimumVersion is the oldest polaris CLI which can use the repository
+//
+type PolarisRepositoryManifest struct {
+	Name           string
+	Description    string
+	Maintainers    []string
+	Catalog        []string
+	MinimumVersion string
+}
+
+// DefaultSyncInterval is how often a repository is synchronized automatically, unless it sets its own SyncInterval
+//
+const DefaultSyncInterval = 24 * time.Hour
+
+// IsCommitHash checks whether a ref is a full commit hash rather than the name of a reference
+//
+func IsCommitHash(ref string) bool {
+	if len(ref) != 40 {
+		return false
+	}
+	for _, c := range ref {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsPinned checks whether a repository is pinned to a tag or an exact commit, rather than following a branch
+//
+func (repository PolarisRepository) IsPinned() bool {
+	return strings.HasPrefix(repository.Ref, "refs/tags/") || IsCommitHash(repository.Ref)
+}
+
+// RefFor turns a ref given on the command line into the Ref of a repository. A full reference name
+// (refs/...) or commit hash is kept as it is, otherwise it names a tag (when tag is set) or a branch
+//
+func RefFor(ref string, tag bool) string {
+	if strings.HasPrefix(ref, "refs/") || IsCommitHash(ref) {
+		return ref
+	}
+	if tag {
+		return fmt.Sprintf("refs/tags/%s", ref)
+	}
+	return fmt.Sprintf("refs/heads/%s", ref)
+}
+
+// checkName checks a repository name or alias is usable within scaffold references: it may not be
+// empty, hold spaces, : or @ (which separate the repository and version of a reference), or have
+// empty, . or .. parts
+//
+func checkName(what string, name string) error {
+	if name == "" || strings.ContainsAny(name, ":@ \t\\") {
+		return fmt.Errorf("Invalid %s \"%s\": it can't be empty or hold spaces, :, @ or \\", what, name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("Invalid %s \"%s\": it can't have empty, . or .. parts", what, name)
+		}
+	}
+	return nil
+}
+
+// ValidateRepositories checks the names and aliases of the repositories: a name may not be within
+// another (such as core and core/stable, which would nest one inside the other), and an alias may not
+// hold a /, or be the name or alias of another repository
+//
+func ValidateRepositories(polarisConfig *PolarisConfig) error {
+	names := []string{}
+	for name := range polarisConfig.Repositories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	aliases := map[string]string{}
+	for _, name := range names {
+		err := checkName("repository name", name)
+		if err != nil {
+			return err
+		}
+		for _, other := range names {
+			if strings.HasPrefix(other, name+"/") {
+				return fmt.Errorf("Repository names %s and %s overlap, as one is within the other", name, other)
+			}
+		}
+
+		alias := polarisConfig.Repositories[name].Alias
+		if alias == "" {
+			continue
+		}
+		err = checkName("alias", alias)
+		if err != nil {
+			return err
+		}
+		if strings.Contains(alias, "/") {
+			return fmt.Errorf("Invalid alias \"%s\" of repository %s: it can't hold a /", alias, name)
+		}
+		if _, ok := polarisConfig.Repositories[alias]; ok && alias != name {
+			return fmt.Errorf("Alias %s of repository %s is the name of another repository", alias, name)
+		}
+		if other, ok := aliases[alias]; ok {
+			return fmt.Errorf("Alias %s is used by both repositories %s and %s", alias, other, name)
+		}
+		aliases[alias] = name
+	}
+	return nil
+}
+
+// PolarisRenderLimits bounds what rendering a scaffold from a repository may produce, as sizes in
+// bytes, a number of files and the time a single template may take. A limit which is not set falls
+// back to DefaultRenderLimits
+//
+type PolarisRenderLimits struct {
+	MaxFileSize     int64         `yaml:",omitempty"`
+	MaxTotalSize    int64         `yaml:",omitempty"`
+	MaxFiles        int           `yaml:",omitempty"`
+	TemplateTimeout time.Duration `yaml:",omitempty"`
+}
+
+// DefaultRenderLimits are the limits for repositories which don't set their own
+//
+var DefaultRenderLimits = PolarisRenderLimits{
+	MaxFileSize:     10 * 1024 * 1024,
+	MaxTotalSize:    100 * 1024 * 1024,
+	MaxFiles:        5000,
+	TemplateTimeout: 10 * time.Second,
+}
+
+// WithDefaults fills in the limits which are not set from DefaultRenderLimits
+//
+func (limits PolarisRenderLimits) WithDefaults() PolarisRenderLimits {
+	if limits.MaxFileSize == 0 {
+		limits.MaxFileSize = DefaultRenderLimits.MaxFileSize
+	}
+	if limits.MaxTotalSize == 0 {
+		limits.MaxTotalSize = DefaultRenderLimits.MaxTotalSize
+	}
+	if limits.MaxFiles == 0 {
+		limits.MaxFiles = DefaultRenderLimits.MaxFiles
+	}
+	if limits.TemplateTimeout == 0 {
+		limits.TemplateTimeout = DefaultRenderLimits.TemplateTimeout
+	}
+	return limits
 }
 
 // PolarisConfig is the structure defining the config
 //
 type PolarisConfig struct {
-	Repositories map[string]PolarisRepository
+	Repositories    map[string]PolarisRepository
+	SyncConcurrency int `yaml:",omitempty"`
 }
 
 // DefaultConfig is what you get when you have no config at the start
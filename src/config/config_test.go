@@ -0,0 +1,104 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckName(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr string
+	}{
+		{"core", ""},
+		{"core/stable", ""},
+		{"my-org/scaffolds.v2", ""},
+		{"", "can't be empty or hold spaces"},
+		{"core stable", "can't be empty or hold spaces"},
+		{"core\tstable", "can't be empty or hold spaces"},
+		{"core:stable", "can't be empty or hold spaces"},
+		{"core@1.0.0", "can't be empty or hold spaces"},
+		{"core\\stable", "can't be empty or hold spaces"},
+		{"/core", "can't have empty, . or .. parts"},
+		{"core/", "can't have empty, . or .. parts"},
+		{"core//stable", "can't have empty, . or .. parts"},
+		{".", "can't have empty, . or .. parts"},
+		{"core/./stable", "can't have empty, . or .. parts"},
+		{"../core", "can't have empty, . or .. parts"},
+		{"core/..", "can't have empty, . or .. parts"},
+		{"..core", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkName("repository name", test.name)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateRepositories(t *testing.T) {
+	tests := []struct {
+		name         string
+		repositories map[string]PolarisRepository
+		wantErr      string
+	}{
+		{"none", map[string]PolarisRepository{}, ""},
+		{"names and aliases", map[string]PolarisRepository{
+			"core/stable":  {Alias: "core"},
+			"core/edge":    {Alias: "edge"},
+			"team/backend": {},
+		}, ""},
+		{"alias of its own name", map[string]PolarisRepository{
+			"core": {Alias: "core"},
+		}, ""},
+		{"invalid name", map[string]PolarisRepository{
+			"core stable": {},
+		}, "Invalid repository name \"core stable\""},
+		{"overlapping names", map[string]PolarisRepository{
+			"core":        {},
+			"core/stable": {},
+		}, "Repository names core and core/stable overlap"},
+		{"names sharing a prefix", map[string]PolarisRepository{
+			"core":   {},
+			"coreos": {},
+		}, ""},
+		{"invalid alias", map[string]PolarisRepository{
+			"core/stable": {Alias: "st@ble"},
+		}, "Invalid alias \"st@ble\""},
+		{"alias with a /", map[string]PolarisRepository{
+			"core/stable": {Alias: "core/s"},
+		}, "it can't hold a /"},
+		{"alias naming another repository", map[string]PolarisRepository{
+			"core/stable": {Alias: "edge"},
+			"edge":        {},
+		}, "Alias edge of repository core/stable is the name of another repository"},
+		{"alias used twice", map[string]PolarisRepository{
+			"core/edge":   {Alias: "core"},
+			"core/stable": {Alias: "core"},
+		}, "Alias core is used by both repositories core/edge and core/stable"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateRepositories(&PolarisConfig{Repositories: test.repositories})
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
@@ -3,8 +3,34 @@ package config
 // PolarisScaffoldParameter holds a parameter
 //
 type PolarisScaffoldParameter struct {
-	Name    string
-	Default string
+	Name        string
+	Default     string
+	Description string
+	Secret      bool
+	Generate    string
+	From        []string
+}
+
+// PolarisScaffoldIteration marks a file or directory within a scaffold to be rendered once for
+// every element of a list parameter
+//
+type PolarisScaffoldIteration struct {
+	Path      string
+	Over      string
+	Separator string
+}
+
+// PolarisScaffoldPatch describes a change made to an existing file of the project a scaffold is
+// unpacked into. Type is merge or append (at the dot separated Path within a YAML or JSON file),
+// json (a list of JSON patch operations in Value) or insert (Value inserted at the line holding Marker)
+//
+type PolarisScaffoldPatch struct {
+	File     string
+	Type     string
+	Path     string
+	Value    string
+	Marker   string
+	Position string
 }
 
 // PolarisScaffoldSpec defines a scaffold spec
@@ -12,23 +38,58 @@ type PolarisScaffoldParameter struct {
 type PolarisScaffoldSpec struct {
 	Description string
 	Help        string
+	Tags        []string
+	Language    string
+	Category    string
 	Parameters  []PolarisScaffoldParameter
+	Iterate     []PolarisScaffoldIteration
+	Patches     []PolarisScaffoldPatch
 }
 
-// PolarisScaffold defines a Scaffold
+// PolarisScaffold defines a Scaffold. Commit is the commit of the repository it was read at (empty
+// for a local repository, which is read in place)
 //
 type PolarisScaffold struct {
-	Spec      PolarisScaffoldSpec
-	Name      string
-	LocalPath string
+	Spec           PolarisScaffoldSpec
+	Name           string
+	LocalPath      string
+	Repository     string
+	RepositoryPath string
+	Commit         string
+	Limits         PolarisRenderLimits
 }
 
-// PolarisProject defines the structure for ./polaris-project.yaml within a local project
+// PolarisProject defines the structure for ./polaris-project.yaml within a local project. ID is
+// unique to the project, wherever it is and whatever it is called. ScaffoldCommit is the commit of
+// the scaffold the project was generated from. Components is always written, so that a project
+// file without it is known to come from a version of polaris which didn't record them
 //
 type PolarisProject struct {
-	Project    string
+	Project        string
+	ID             string `yaml:",omitempty"`
+	Parameters     map[string]string
+	Scaffold       string
+	ScaffoldCommit string `yaml:",omitempty"`
+	Components     map[string]PolarisProjectComponent
+}
+
+// PolarisProjectComponent records a component unpacked into a project, so that it can be rendered again
+// from the same commit of its scaffold
+//
+type PolarisProjectComponent struct {
+	Scaffold   string
+	Commit     string `yaml:",omitempty"`
 	Parameters map[string]string
+	Features   []PolarisProjectFeature `yaml:",omitempty"`
+}
+
+// PolarisProjectFeature records a feature pack added to a component, so that it can be rendered again
+// from the same commit of its scaffold
+//
+type PolarisProjectFeature struct {
 	Scaffold   string
+	Commit     string `yaml:",omitempty"`
+	Parameters map[string]string
 }
 
 // PolarisComponent for generating a Component within a project
@@ -0,0 +1,9 @@
+package config
+
+// PolarisWorkspace defines the structure for ./polaris-workspace.yaml listing
+// the project directories within a workspace
+//
+type PolarisWorkspace struct {
+	Projects []string
+	Discover bool
+}
@@ -5,11 +5,11 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	git "gopkg.in/src-d/go-git.v4"
-	"gopkg.in/src-d/go-git.v4/plumbing/object"
-	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 
 	"github.com/synthesis-labs/polaris-cli/src/cluster"
 	"github.com/synthesis-labs/polaris-cli/src/config"
@@ -17,28 +17,277 @@ import (
 	"github.com/synthesis-labs/polaris-cli/src/repo"
 	"github.com/synthesis-labs/polaris-cli/src/scaffold"
 	"github.com/synthesis-labs/polaris-cli/src/status"
+	"github.com/synthesis-labs/polaris-cli/src/workspace"
 	"github.com/urfave/cli"
 )
 
-func main() {
-	// Get current users home folder
-	//
-	polarisHome, polarisConfig := config.GetConfig()
-	needSync, err := repo.NeedSynchronizeRepositories(polarisHome, polarisConfig)
+// getWorkspaceProjects finds the project directories of the workspace in the current directory
+//
+func getWorkspaceProjects() ([]string, error) {
+	localWorkspace, err := workspace.GetLocalWorkspace(".")
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+
+	directories, err := workspace.FindProjects(".", localWorkspace)
+	if err != nil {
+		return nil, err
+	}
+	if len(directories) == 0 {
+		return nil, errors.New("No projects found in workspace")
+	}
+
+	return directories, nil
+}
+
+// getComponentScaffolds gets the scaffold of every component recorded in the project, keyed by component name. Like
+// the project's own scaffold, each is read at the commit the component was generated from, so that rendering it again
+// changes nothing but what is being changed
+//
+func getComponentScaffolds(polarisHome string, polarisConfig *config.PolarisConfig, project *config.PolarisProject) (map[string]*config.PolarisScaffold, error) {
+	componentScaffolds := map[string]*config.PolarisScaffold{}
+	for componentName, component := range project.Components {
+		componentScaffold, err := repo.GetComponentAt(polarisHome, polarisConfig, component.Scaffold, component.Commit)
+		if err != nil {
+			return nil, err
+		}
+		componentScaffolds[componentName] = componentScaffold
+	}
+	return componentScaffolds, nil
+}
+
+// getFeatureScaffolds gets the scaffold of every feature pack recorded in the project, keyed by scaffold.FeatureKey
+//
+func getFeatureScaffolds(polarisHome string, polarisConfig *config.PolarisConfig, project *config.PolarisProject) (map[string]*config.PolarisScaffold, error) {
+	featureScaffolds := map[string]*config.PolarisScaffold{}
+	for _, component := range project.Components {
+		for _, feature := range component.Features {
+			if _, found := featureScaffolds[scaffold.FeatureKey(feature)]; found {
+				continue
+			}
+			featureScaffold, err := repo.GetFeatureAt(polarisHome, polarisConfig, feature.Scaffold, feature.Commit)
+			if err != nil {
+				return nil, err
+			}
+			featureScaffolds[scaffold.FeatureKey(feature)] = featureScaffold
+		}
+	}
+	return featureScaffolds, nil
+}
+
+// getProjectScaffolds gets the scaffolds of the project and everything unpacked into it, either at the commits
+// recorded for them (as they were generated) or as they are now
+//
+func getProjectScaffolds(polarisHome string, polarisConfig *config.PolarisConfig, project *config.PolarisProject, recorded bool) (*scaffold.ProjectScaffolds, error) {
+	var err error
+	scaffolds := &scaffold.ProjectScaffolds{}
+	if recorded {
+		scaffolds.Project, err = repo.GetProjectAt(polarisHome, polarisConfig, project.Scaffold, project.ScaffoldCommit)
+		if err != nil {
+			return nil, err
+		}
+		scaffolds.Components, err = getComponentScaffolds(polarisHome, polarisConfig, project)
+		if err != nil {
+			return nil, err
+		}
+		scaffolds.Features, err = getFeatureScaffolds(polarisHome, polarisConfig, project)
+		if err != nil {
+			return nil, err
+		}
+		return scaffolds, nil
+	}
+
+	scaffolds.Project, err = repo.GetProject(polarisHome, polarisConfig, project.Scaffold)
+	if err != nil {
+		return nil, err
 	}
-	if needSync {
-		err := repo.SynchronizeRepositories(polarisHome, polarisConfig, false)
+	scaffolds.Components = map[string]*config.PolarisScaffold{}
+	scaffolds.Features = map[string]*config.PolarisScaffold{}
+	for componentName, component := range project.Components {
+		scaffolds.Components[componentName], err = repo.GetComponent(polarisHome, polarisConfig, component.Scaffold)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
+		}
+		for _, feature := range component.Features {
+			scaffolds.Features[scaffold.FeatureKey(feature)], err = repo.GetFeature(polarisHome, polarisConfig, feature.Scaffold)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return scaffolds, nil
+}
+
+// upgradeProject upgrades the project within the current directory from the scaffolds it was generated from to
+// the scaffolds as they are now. A dry run prints the diff of what would change instead
+//
+func upgradeProject(polarisHome string, polarisConfig *config.PolarisConfig, project *config.PolarisProject, dryRun bool) error {
+	oldScaffolds, err := getProjectScaffolds(polarisHome, polarisConfig, project, true)
+	if err != nil {
+		return err
+	}
+	newScaffolds, err := getProjectScaffolds(polarisHome, polarisConfig, project, false)
+	if err != nil {
+		return err
+	}
+
+	report, err := scaffold.UpgradeProject(polarisHome, oldScaffolds, newScaffolds, project, dryRun)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		scaffold.WriteChanges(os.Stdout, report.Changes)
+	}
+	scaffold.PrintRerenderReport(report)
+	return nil
+}
+
+// lintProject prints the problems with the project within the current directory, failing if there are any
+//
+func lintProject(polarisHome string, polarisConfig *config.PolarisConfig, project *config.PolarisProject) error {
+	scaffolds, err := getProjectScaffolds(polarisHome, polarisConfig, project, true)
+	if err != nil {
+		return err
+	}
+
+	problems := scaffold.LintProject(polarisHome, scaffolds, project)
+	for _, problem := range problems {
+		fmt.Println(" -", problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problems found", len(problems))
+	}
+	return nil
+}
+
+// forEachWorkspaceProject runs an operation on the project within the current directory against every project of
+// the workspace in turn, from within the project's directory, and reports the results together
+//
+func forEachWorkspaceProject(action func(project *config.PolarisProject) error) error {
+	directories, err := getWorkspaceProjects()
+	if err != nil {
+		return err
+	}
+
+	results := workspace.ForEachProject(directories, func(directory string, project *config.PolarisProject) error {
+		fmt.Println("==>", project.Project, "(", directory, ")")
+		return workspace.InDirectory(directory, func() error {
+			return action(project)
+		})
+	})
+	fmt.Println()
+	return workspace.PrintReport(results)
+}
+
+// parseSetParameters parses key=value pairs from --set flags or arguments
+//
+func parseSetParameters(values []string) (map[string]string, error) {
+	parameters := map[string]string{}
+	for _, value := range values {
+		split := strings.SplitN(value, "=", 2)
+		if len(split) != 2 {
+			return nil, fmt.Errorf("Invalid parameter %s (expected key=value)", value)
+		}
+		parameters[split[0]] = split[1]
+	}
+	return parameters, nil
+}
+
+// describeScaffold prints the details of a scaffold, its readme and the files it would create
+//
+func describeScaffold(detail *config.PolarisScaffold, paths []string) error {
+	fmt.Println("Name:", detail.Name)
+	fmt.Println("Description:", detail.Spec.Description)
+	fmt.Println("Help:", detail.Spec.Help)
+	fmt.Println("Parameters:")
+	for _, param := range detail.Spec.Parameters {
+		switch {
+		case param.Generate != "":
+			fmt.Println(" -", param.Name, "generated", param.Generate, "(secret)")
+		case param.Secret:
+			fmt.Println(" -", param.Name, "(secret)")
+		default:
+			fmt.Println(" -", param.Name, "default", param.Default)
+		}
+		if param.Description != "" {
+			fmt.Println("   ", param.Description)
 		}
 	}
 
+	readme, err := scaffold.RenderReadme(detail)
+	if err != nil {
+		return err
+	}
+	if readme != "" {
+		fmt.Println()
+		fmt.Println(readme)
+	}
+
+	fmt.Println()
+	fmt.Println("Files:")
+	scaffold.PrintTree(paths)
+
+	return nil
+}
+
+func main() {
+	// Get current users home folder
+	//
+	polarisHome, polarisConfig := config.GetConfig()
+
 	app := cli.NewApp()
 	app.Name = "Polaris"
 	app.Usage = "scaffold polaris projects and components"
-	app.Version = "0.0.3"
+	app.Version = config.Version
+	app.Flags = []cli.Flag{
+		cli.BoolFlag{Name: "offline", EnvVar: "POLARIS_OFFLINE", Usage: "Work from the cached repositories, without syncing them"},
+		cli.BoolFlag{Name: "allow-unverified", Usage: "Accept repository content which isn't signed by a trusted key"},
+	}
+
+	// Repositories which are due are synchronized before running any command, unless offline
+	//
+	app.Before = func(c *cli.Context) error {
+		options.SetOffline(c.Bool("offline"))
+		options.SetAllowUnverified(c.Bool("allow-unverified"))
+		if c.NArg() == 0 || c.Args().First() == "help" {
+			return nil
+		}
+
+		// Repositories with overlapping names can only be fixed through the repo commands
+		//
+		err := config.ValidateRepositories(polarisConfig)
+		if err != nil {
+			if c.Args().First() != "repo" {
+				return fmt.Errorf("%s (fix this with polaris repo remove, or in config.yaml)", err)
+			}
+			fmt.Println("Warning:", err)
+		}
+		if options.IsOffline() {
+			return nil
+		}
+
+		due, err := repo.NeedSynchronizeRepositories(polarisHome, polarisConfig)
+		if err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+
+		results, err := repo.SynchronizeRepositories(polarisHome, polarisConfig, false, due...)
+		if err != nil {
+			fmt.Println("Warning: unable to sync repositories - using the cached repositories:", err)
+			return nil
+		}
+
+		// A repository which failed to sync still has the scaffolds from the last time it did
+		//
+		err = repo.PrintSyncReport(results)
+		if err != nil {
+			fmt.Println("Warning:", err, "- using the cached repositories")
+		}
+		return nil
+	}
 
 	app.Commands = []cli.Command{
 		{
@@ -106,39 +355,93 @@ func main() {
 					},
 					Action: func(c *cli.Context) error {
 						options.SetVerbose(c.Bool("verbose"))
-						for repoName, repoConfig := range polarisConfig.Repositories {
 
-							// Open the repository
+						// In the order scaffold paths are resolved in: highest priority first, then by name
+						//
+						repoNames := []string{}
+						for repoName := range polarisConfig.Repositories {
+							repoNames = append(repoNames, repoName)
+						}
+						sort.Slice(repoNames, func(i, j int) bool {
+							priorityI := polarisConfig.Repositories[repoNames[i]].Priority
+							priorityJ := polarisConfig.Repositories[repoNames[j]].Priority
+							if priorityI != priorityJ {
+								return priorityI > priorityJ
+							}
+							return repoNames[i] < repoNames[j]
+						})
+
+						for _, repoName := range repoNames {
+							repoConfig := polarisConfig.Repositories[repoName]
+							details := ""
+							if repoConfig.Alias != "" {
+								details += fmt.Sprintf(" [alias %s]", repoConfig.Alias)
+							}
+							if repoConfig.Priority != 0 {
+								details += fmt.Sprintf(" [priority %d]", repoConfig.Priority)
+							}
+
+							// What the repository says about itself
 							//
-							repository, err := git.PlainOpen(fmt.Sprintf("%s/repos/%s", polarisHome, repoName))
+							manifest, err := repo.GetManifest(polarisHome, repoName, repoConfig)
 							if err != nil {
-								log.Fatal(err)
+								fmt.Println("Warning:", repoName+":", err)
+							}
+							printManifest := func() {
+								if manifest == nil {
+									return
+								}
+								if manifest.Name != "" {
+									fmt.Println("    name:", manifest.Name)
+								}
+								if manifest.Description != "" {
+									fmt.Println("    description:", manifest.Description)
+								}
+								if len(manifest.Maintainers) > 0 {
+									fmt.Println("    maintainers:", strings.Join(manifest.Maintainers, ", "))
+								}
+								if manifest.MinimumVersion != "" {
+									fmt.Println("    needs polaris", manifest.MinimumVersion, "or later")
+								}
 							}
 
-							_, err = repository.Head()
-							if err != nil {
-								log.Fatal(err)
+							if repoConfig.Local != "" {
+								fmt.Println(repoName, "->", repoConfig.Local, "(local)"+details)
+								printManifest()
+								continue
 							}
 
-							logIter, err := repository.Log(&git.LogOptions{})
-							if err != nil {
-								return err
+							// Open the repository, reporting a clone which is missing (or broken) against its row
+							//
+							repository, err := git.PlainOpen(repo.RepositoryPath(polarisHome, repoName, repoConfig))
+							if err == git.ErrRepositoryNotExists {
+								fmt.Println(repoName, "->", repoConfig.URI, repoConfig.Ref, "( not cloned, run polaris repo update )"+details)
+								continue
+							} else if err != nil {
+								fmt.Println(repoName, "->", repoConfig.URI, repoConfig.Ref, "( unable to open the clone:", err, ")"+details)
+								continue
 							}
 
-							// Get the head commit - Just get the first one and stop
+							// Get the head commit
 							//
-							var headCommit *object.Commit
-							logIter.ForEach(func(obj *object.Commit) error {
-								headCommit = obj
-								return storer.ErrStop
-							})
+							head, err := repository.Head()
+							if err != nil {
+								fmt.Println(repoName, "->", repoConfig.URI, repoConfig.Ref, "( unable to read the clone:", err, ")"+details)
+								continue
+							}
+							headCommit, err := repository.CommitObject(head.Hash())
+							if err != nil {
+								fmt.Println(repoName, "->", repoConfig.URI, repoConfig.Ref, "( unable to read the clone:", err, ")"+details)
+								continue
+							}
 
 							// Print whatever we need
 							//
 							fmt.Println(repoName, "->",
 								repoConfig.URI,
 								repoConfig.Ref,
-								"(", headCommit.Author.Name, ",", headCommit.Hash.String()[:7], ")")
+								"(", headCommit.Author.Name, ",", headCommit.Hash.String()[:7], ")"+details)
+							printManifest()
 
 							//							fmt.Println(repoName, "->", , headCommit.Message[:15])
 						}
@@ -148,12 +451,55 @@ func main() {
 				{
 					Name:      "add",
 					Usage:     "Add a repository",
-					ArgsUsage: "<NAME> <URL> <Ref>",
+					ArgsUsage: "<NAME> <URL> <Ref> | --local <NAME> <DIRECTORY>",
 					Flags: []cli.Flag{
 						cli.BoolFlag{Name: "verbose", Usage: "Verbose output"},
+						cli.BoolFlag{Name: "local", Usage: "Add a local directory which is read in place, rather than a git repository"},
+						cli.StringFlag{Name: "alias", Usage: "Short name for the repository in scaffold references (<alias>:<path>)"},
+						cli.IntFlag{Name: "priority", Usage: "Priority of the repository when a scaffold path is in several repositories (highest wins)"},
+						cli.BoolFlag{Name: "tag", Usage: "Pin the repository to the tag <Ref>, rather than following the branch <Ref>"},
+						cli.StringFlag{Name: "auth", Usage: "How to authenticate to a private repository: ssh-key, ssh-agent, basic, token or netrc"},
+						cli.StringFlag{Name: "user", Usage: "User to authenticate as (ssh defaults to git)"},
+						cli.StringFlag{Name: "key", Usage: "Path of the ssh key, for ssh-key (default: ~/.ssh/id_rsa)"},
+						cli.StringFlag{Name: "password-env", Usage: "Environment variable holding the password, token or key passphrase"},
+						cli.StringSliceFlag{Name: "trusted-key", Usage: "File of an armored PGP public key which must have signed the repository (repeat for several)"},
 					},
 					Action: func(c *cli.Context) error {
 						options.SetVerbose(c.Bool("verbose"))
+
+						// A local repository is just registered, as there is nothing to sync
+						//
+						if c.Bool("local") {
+							if c.NArg() != 2 {
+								cli.ShowCommandHelp(c, "add")
+								return errors.New("Invalid number of arguments")
+							}
+							name := c.Args().Get(0)
+							directory, err := filepath.Abs(c.Args().Get(1))
+							if err != nil {
+								return err
+							}
+							if info, err := os.Stat(directory); err != nil || !info.IsDir() {
+								return fmt.Errorf("%s is not a directory", directory)
+							}
+
+							polarisConfig.Repositories[name] = config.PolarisRepository{Local: directory, Alias: c.String("alias"), Priority: c.Int("priority")}
+							err = config.ValidateRepositories(polarisConfig)
+							if err != nil {
+								return err
+							}
+							warning, err := repo.CheckManifest(polarisHome, name, polarisConfig.Repositories[name])
+							if warning != "" {
+								fmt.Println("Warning:", warning)
+							}
+							if err != nil {
+								return err
+							}
+							config.SaveConfig(polarisHome, polarisConfig)
+							fmt.Println("Added local repository", name, "->", directory)
+							return nil
+						}
+
 						if c.NArg() != 3 {
 							cli.ShowCommandHelp(c, "add")
 							return errors.New("Invalid number of arguments")
@@ -163,16 +509,45 @@ func main() {
 						ref := c.Args().Get(2)
 
 						polarisConfig.Repositories[name] = config.PolarisRepository{
-							URI: url,
-							Ref: fmt.Sprintf("refs/heads/%s", ref),
+							URI:         url,
+							Ref:         config.RefFor(ref, c.Bool("tag")),
+							Alias:       c.String("alias"),
+							Priority:    c.Int("priority"),
+							TrustedKeys: c.StringSlice("trusted-key"),
+							Auth: config.PolarisRepositoryAuth{
+								Type:        c.String("auth"),
+								User:        c.String("user"),
+								KeyPath:     c.String("key"),
+								PasswordEnv: c.String("password-env"),
+							},
+						}
+						err := config.ValidateRepositories(polarisConfig)
+						if err != nil {
+							return err
 						}
 						config.SaveConfig(polarisHome, polarisConfig)
+						if options.IsOffline() {
+							fmt.Println("Offline, repository", name, "will be synced when next online")
+							return nil
+						}
 
-						err := repo.SynchronizeRepositories(polarisHome, polarisConfig, false, name)
+						results, err := repo.SynchronizeRepositories(polarisHome, polarisConfig, false, name)
 						if err != nil {
 							log.Fatal(err)
 						}
-						return nil
+
+						// A repository needing a later polaris, or which isn't signed by a trusted key, isn't kept
+						//
+						for _, result := range results {
+							_, incompatible := result.Err.(*repo.IncompatibleError)
+							_, unverified := result.Err.(*repo.UnverifiedError)
+							if incompatible || unverified {
+								delete(polarisConfig.Repositories, name)
+								config.SaveConfig(polarisHome, polarisConfig)
+								return result.Err
+							}
+						}
+						return repo.PrintSyncReport(results)
 					},
 				},
 				{
@@ -189,31 +564,64 @@ func main() {
 							return errors.New("Invalid number of arguments")
 						}
 						name := c.Args().Get(0)
+						repoConfig := polarisConfig.Repositories[name]
 
 						delete(polarisConfig.Repositories, name)
 						config.SaveConfig(polarisHome, polarisConfig)
 
-						// Must delete repository too
+						// Must delete repository too (but never the directory of a local repository)
 						//
-						reposHome := fmt.Sprintf("%s/repos/", polarisHome)
-						os.RemoveAll(fmt.Sprintf("%s/%s", reposHome, name))
+						if repoConfig.Local == "" {
+							reposHome := fmt.Sprintf("%s/repos/", polarisHome)
+							os.RemoveAll(fmt.Sprintf("%s/%s", reposHome, name))
+						}
+						os.Remove(fmt.Sprintf("%s/sync/%s.yaml", polarisHome, name))
+						os.Remove(fmt.Sprintf("%s/index/%s.yaml", polarisHome, name))
 
 						return nil
 					},
 				},
+				{
+					Name:  "status",
+					Usage: "Show the sync state of each repository, what it provides and whether its remote is ahead",
+					Flags: []cli.Flag{
+						cli.BoolFlag{Name: "verbose", Usage: "Verbose output"},
+					},
+					Action: func(c *cli.Context) error {
+						options.SetVerbose(c.Bool("verbose"))
+
+						statuses, err := repo.GetRepositoryStatuses(polarisHome, polarisConfig)
+						if err != nil {
+							return err
+						}
+
+						repo.PrintRepositoryStatuses(statuses)
+						return nil
+					},
+				},
 				{
 					Name:  "update",
 					Usage: "Update the scaffolds in all repositories",
 					Flags: []cli.Flag{
 						cli.BoolFlag{Name: "verbose", Usage: "Verbose output"},
 						cli.BoolFlag{Name: "force", Usage: "Force a full refresh"},
+						cli.IntFlag{Name: "concurrency", Usage: "How many repositories to sync at once (default: syncconcurrency in config.yaml, or 4)"},
 					},
 					Action: func(c *cli.Context) error {
 						options.SetVerbose(c.Bool("verbose"))
+						if c.Int("concurrency") > 0 {
+							polarisConfig.SyncConcurrency = c.Int("concurrency")
+						}
+						if options.IsOffline() {
+							return errors.New("Unable to update repositories while offline")
+						}
 
-						err := repo.SynchronizeRepositories(polarisHome, polarisConfig, c.Bool("force"))
+						results, err := repo.SynchronizeRepositories(polarisHome, polarisConfig, c.Bool("force"))
+						if err != nil {
+							return err
+						}
 
-						return err
+						return repo.PrintSyncReport(results)
 					},
 				},
 			},
@@ -227,6 +635,7 @@ func main() {
 					Usage: "List projects available to scaffold",
 					Flags: []cli.Flag{
 						cli.BoolFlag{Name: "verbose", Usage: "Verbose output"},
+						cli.BoolFlag{Name: "versions", Usage: "Show the versions available"},
 					},
 					Action: func(c *cli.Context) error {
 						options.SetVerbose(c.Bool("verbose"))
@@ -235,8 +644,16 @@ func main() {
 							log.Fatal(err)
 						}
 
-						for name, detail := range scaffolds {
+						for _, name := range repo.CatalogNames(polarisHome, polarisConfig, scaffolds) {
+							detail := scaffolds[name]
 							fmt.Println(name, "->", detail.Spec.Description)
+							if c.Bool("versions") {
+								versions, err := repo.ListProjectVersions(polarisHome, polarisConfig, name)
+								if err != nil {
+									return err
+								}
+								fmt.Println("  versions:", strings.Join(versions, ", "))
+							}
 						}
 
 						return nil
@@ -248,6 +665,8 @@ func main() {
 					Usage:     "Describe a project",
 					Flags: []cli.Flag{
 						cli.BoolFlag{Name: "verbose", Usage: "Verbose output"},
+						cli.StringFlag{Name: "name", Value: "myproject", Usage: "Local name to preview the files with"},
+						cli.StringSliceFlag{Name: "set", Usage: "Template parameter to preview the files with (key=value)"},
 					},
 					Action: func(c *cli.Context) error {
 						options.SetVerbose(c.Bool("verbose"))
@@ -257,20 +676,22 @@ func main() {
 						}
 						scaffoldName := c.Args().Get(0)
 
-						scaffold, err := repo.GetProject(polarisHome, polarisConfig, scaffoldName)
+						parameters, err := parseSetParameters(c.StringSlice("set"))
+						if err != nil {
+							return err
+						}
+
+						projectScaffold, err := repo.GetProject(polarisHome, polarisConfig, scaffoldName)
 						if err != nil {
 							log.Fatal(err)
 						}
 
-						fmt.Println("Name:", scaffoldName)
-						fmt.Println("Description:", scaffold.Spec.Description)
-						fmt.Println("Help:", scaffold.Spec.Help)
-						fmt.Println("Parameters:")
-						for _, param := range scaffold.Spec.Parameters {
-							fmt.Println(" -", param.Name, "default", param.Default)
+						paths, err := scaffold.PreviewProject(projectScaffold, parameters, c.String("name"))
+						if err != nil {
+							return err
 						}
 
-						return nil
+						return describeScaffold(projectScaffold, paths)
 					},
 				},
 				{
@@ -279,7 +700,7 @@ func main() {
 					Usage:     "Unpack a project locally",
 					Flags: []cli.Flag{
 						cli.BoolFlag{Name: "verbose", Usage: "Verbose output"},
-						cli.StringFlag{Name: "from", Usage: "From which project upstream (default: core/stable/starter/project)"},
+						cli.StringFlag{Name: "from", Usage: "From which project upstream, optionally @version (default: core/stable/starter/project)"},
 						cli.BoolFlag{Name: "overwrite", Usage: "Allow overwriting of target files"},
 						cli.StringFlag{Name: "parameters", Usage: "Provide template parameters"},
 					},
@@ -310,7 +731,7 @@ func main() {
 						if err != nil {
 							return err
 						}
-						err = scaffold.UnpackProject(applicationScaffold, parameters, localName, c.Bool("overwrite"))
+						err = scaffold.UnpackProject(polarisHome, applicationScaffold, parameters, localName, c.Bool("overwrite"))
 						if err != nil {
 							return err
 						}
@@ -359,6 +780,157 @@ func main() {
 						return nil
 					},
 				},
+				{
+					Name:      "rename",
+					ArgsUsage: "<new name>",
+					Usage:     "Rename the project in the current directory",
+					Flags: []cli.Flag{
+						cli.BoolFlag{Name: "verbose", Usage: "Verbose output"},
+					},
+					Action: func(c *cli.Context) error {
+						options.SetVerbose(c.Bool("verbose"))
+						if c.NArg() != 1 {
+							cli.ShowCommandHelp(c, "rename")
+							return errors.New("Invalid number of arguments")
+						}
+						newName := c.Args().Get(0)
+
+						project, err := scaffold.GetLocalProject("project")
+						if err != nil {
+							return err
+						}
+
+						// Everything generated is rendered again, so we need all the scaffolds
+						//
+						projectScaffold, err := repo.GetProjectAt(polarisHome, polarisConfig, project.Scaffold, project.ScaffoldCommit)
+						if err != nil {
+							return err
+						}
+						componentScaffolds, err := getComponentScaffolds(polarisHome, polarisConfig, project)
+						if err != nil {
+							return err
+						}
+						featureScaffolds, err := getFeatureScaffolds(polarisHome, polarisConfig, project)
+						if err != nil {
+							return err
+						}
+
+						report, directory, err := scaffold.RenameProject(polarisHome, projectScaffold, componentScaffolds, featureScaffolds, project, newName)
+						if err != nil {
+							return err
+						}
+						scaffold.PrintRerenderReport(report)
+
+						if directory != "" {
+							fmt.Println("Renamed project to", newName, "(the project is now in", directory+")")
+						} else {
+							fmt.Println("Renamed project to", newName, "(the project directory itself has not been renamed)")
+						}
+						return nil
+					},
+				},
+				{
+					Name:      "set",
+					ArgsUsage: "<key=value>...",
+					Usage:     "Change parameters of the project in the current directory, rendering the affected files again",
+					Flags: []cli.Flag{
+						cli.BoolFlag{Name: "verbose", Usage: "Verbose output"},
+					},
+					Action: func(c *cli.Context) error {
+						options.SetVerbose(c.Bool("verbose"))
+						if c.NArg() < 1 {
+							cli.ShowCommandHelp(c, "set")
+							return errors.New("Invalid number of arguments")
+						}
+						parameters, err := parseSetParameters(c.Args())
+						if err != nil {
+							return err
+						}
+
+						project, err := scaffold.GetLocalProject("project")
+						if err != nil {
+							return err
+						}
+
+						// The components may use the project's parameters, so they are rendered again too
+						//
+						projectScaffold, err := repo.GetProjectAt(polarisHome, polarisConfig, project.Scaffold, project.ScaffoldCommit)
+						if err != nil {
+							return err
+						}
+						componentScaffolds, err := getComponentScaffolds(polarisHome, polarisConfig, project)
+						if err != nil {
+							return err
+						}
+						featureScaffolds, err := getFeatureScaffolds(polarisHome, polarisConfig, project)
+						if err != nil {
+							return err
+						}
+
+						report, err := scaffold.SetProjectParameters(polarisHome, projectScaffold, componentScaffolds, featureScaffolds, project, parameters)
+						if err != nil {
+							return err
+						}
+						scaffold.PrintRerenderReport(report)
+
+						fmt.Println("Updated parameters of project", project.Project)
+						return nil
+					},
+				},
+				{
+					Name:  "diff",
+					Usage: "Show what upgrading the project in the current directory to its scaffolds as they are now would change",
+					Flags: []cli.Flag{
+						cli.BoolFlag{Name: "verbose", Usage: "Verbose output"},
+					},
+					Action: func(c *cli.Context) error {
+						options.SetVerbose(c.Bool("verbose"))
+
+						project, err := scaffold.GetLocalProject("project")
+						if err != nil {
+							return err
+						}
+						return upgradeProject(polarisHome, polarisConfig, project, true)
+					},
+				},
+				{
+					Name:  "upgrade",
+					Usage: "Upgrade the project in the current directory to its scaffolds as they are now, rendering it again",
+					Flags: []cli.Flag{
+						cli.BoolFlag{Name: "verbose", Usage: "Verbose output"},
+					},
+					Action: func(c *cli.Context) error {
+						options.SetVerbose(c.Bool("verbose"))
+
+						project, err := scaffold.GetLocalProject("project")
+						if err != nil {
+							return err
+						}
+						err = upgradeProject(polarisHome, polarisConfig, project, false)
+						if err != nil {
+							return err
+						}
+
+						fmt.Println("Upgraded project", project.Project)
+						return nil
+					},
+				},
+				{
+					Name:  "lint",
+					Usage: "Check the project in the current directory against the scaffolds it was generated from",
+					Flags: []cli.Flag{
+						cli.BoolFlag{Name: "verbose", Usage: "Verbose output"},
+					},
+					Action: func(c *cli.Context) error {
+						options.SetVerbose(c.Bool("verbose"))
+
+						project, err := scaffold.GetLocalProject("project")
+						if err != nil {
+							return err
+						}
+						return lintProject(polarisHome, polarisConfig, project)
+					},
+				},
 			},
 		},
 		{
@@ -368,6 +940,9 @@ func main() {
 				{
 					Name:  "list",
 					Usage: "List components available to scaffold into your project",
+					Flags: []cli.Flag{
+						cli.BoolFlag{Name: "versions", Usage: "Show the versions available"},
+					},
 					Action: func(c *cli.Context) error {
 
 						components, err := repo.ListComponents(polarisHome, polarisConfig)
@@ -375,8 +950,16 @@ func main() {
 							log.Fatal(err)
 						}
 
-						for name, detail := range components {
+						for _, name := range repo.CatalogNames(polarisHome, polarisConfig, components) {
+							detail := components[name]
 							fmt.Println(name, "->", detail.Spec.Description)
+							if c.Bool("versions") {
+								versions, err := repo.ListComponentVersions(polarisHome, polarisConfig, name)
+								if err != nil {
+									return err
+								}
+								fmt.Println("  versions:", strings.Join(versions, ", "))
+							}
 						}
 
 						return nil
@@ -387,13 +970,42 @@ func main() {
 					Name:      "describe",
 					ArgsUsage: "<NAME>",
 					Usage:     "Describe a component",
+					Flags: []cli.Flag{
+						cli.BoolFlag{Name: "verbose", Usage: "Verbose output"},
+						cli.StringFlag{Name: "name", Value: "mycomponent", Usage: "Local name to preview the files with"},
+						cli.StringSliceFlag{Name: "set", Usage: "Template parameter to preview the files with (key=value)"},
+					},
 					Action: func(c *cli.Context) error {
+						options.SetVerbose(c.Bool("verbose"))
 						if c.NArg() != 1 {
 							cli.ShowCommandHelp(c, "describe")
 							return errors.New("Invalid number of arguments")
 						}
+						scaffoldName := c.Args().Get(0)
 
-						return nil
+						parameters, err := parseSetParameters(c.StringSlice("set"))
+						if err != nil {
+							return err
+						}
+
+						componentScaffold, err := repo.GetComponent(polarisHome, polarisConfig, scaffoldName)
+						if err != nil {
+							return err
+						}
+
+						// Preview within the local project if there is one, otherwise an example project
+						//
+						project, err := scaffold.GetLocalProject("project")
+						if err != nil {
+							project = &config.PolarisProject{Project: "myproject", Parameters: map[string]string{}}
+						}
+
+						paths, err := scaffold.PreviewComponent(polarisHome, componentScaffold, project, parameters, scaffoldName, c.String("name"))
+						if err != nil {
+							return err
+						}
+
+						return describeScaffold(componentScaffold, paths)
 					},
 				},
 				{
@@ -401,7 +1013,7 @@ func main() {
 					ArgsUsage: "<local name>",
 					Usage:     "Unpack a component locally",
 					Flags: []cli.Flag{
-						cli.StringFlag{Name: "from", Usage: "From which component upstream (default: core/stable/starter/kotlin/microservice)"},
+						cli.StringFlag{Name: "from", Usage: "From which component upstream, optionally @version (default: core/stable/starter/kotlin/microservice)"},
 						cli.BoolFlag{Name: "overwrite", Usage: "Allow overwriting of target files"},
 						cli.StringFlag{Name: "parameters", Usage: "Provide template parameters"},
 					},
@@ -450,19 +1062,298 @@ func main() {
 							return err
 						}
 
-						err = scaffold.UnpackComponent(componentScaffold, project, parameters, fromOption, localName, c.Bool("overwrite"))
+						err = scaffold.UnpackComponent(polarisHome, componentScaffold, project, parameters, fromOption, localName, c.Bool("overwrite"))
+						if err != nil {
+							return err
+						}
+
+						return nil
+					},
+				},
+				{
+					Name:      "rename",
+					ArgsUsage: "<old name> <new name>",
+					Usage:     "Rename a component of the project in the current directory",
+					Flags: []cli.Flag{
+						cli.BoolFlag{Name: "verbose", Usage: "Verbose output"},
+					},
+					Action: func(c *cli.Context) error {
+						options.SetVerbose(c.Bool("verbose"))
+						if c.NArg() != 2 {
+							cli.ShowCommandHelp(c, "rename")
+							return errors.New("Invalid number of arguments")
+						}
+						oldName := c.Args().Get(0)
+						newName := c.Args().Get(1)
+
+						project, err := scaffold.GetLocalProject("project")
+						if err != nil {
+							return err
+						}
+
+						component, found := project.Components[oldName]
+						if !found {
+							return fmt.Errorf("Component %s is not recorded in polaris-project.yaml", oldName)
+						}
+						componentScaffold, err := repo.GetComponentAt(polarisHome, polarisConfig, component.Scaffold, component.Commit)
+						if err != nil {
+							return err
+						}
+
+						featureScaffolds, err := getFeatureScaffolds(polarisHome, polarisConfig, project)
+						if err != nil {
+							return err
+						}
+
+						report, err := scaffold.RenameComponent(polarisHome, componentScaffold, featureScaffolds, project, oldName, newName)
+						if err != nil {
+							return err
+						}
+						scaffold.PrintRerenderReport(report)
+
+						fmt.Println("Renamed component", oldName, "to", newName)
+						return nil
+					},
+				},
+				{
+					Name:      "set",
+					ArgsUsage: "<component name> <key=value>...",
+					Usage:     "Change parameters of a component of the project in the current directory, rendering the affected files again",
+					Flags: []cli.Flag{
+						cli.BoolFlag{Name: "verbose", Usage: "Verbose output"},
+					},
+					Action: func(c *cli.Context) error {
+						options.SetVerbose(c.Bool("verbose"))
+						if c.NArg() < 2 {
+							cli.ShowCommandHelp(c, "set")
+							return errors.New("Invalid number of arguments")
+						}
+						componentName := c.Args().Get(0)
+						parameters, err := parseSetParameters(c.Args().Tail())
+						if err != nil {
+							return err
+						}
+
+						project, err := scaffold.GetLocalProject("project")
+						if err != nil {
+							return err
+						}
+
+						component, found := project.Components[componentName]
+						if !found {
+							return fmt.Errorf("Component %s is not recorded in polaris-project.yaml", componentName)
+						}
+						componentScaffold, err := repo.GetComponentAt(polarisHome, polarisConfig, component.Scaffold, component.Commit)
+						if err != nil {
+							return err
+						}
+
+						featureScaffolds, err := getFeatureScaffolds(polarisHome, polarisConfig, project)
+						if err != nil {
+							return err
+						}
+
+						report, err := scaffold.SetComponentParameters(polarisHome, componentScaffold, featureScaffolds, project, componentName, parameters)
 						if err != nil {
 							return err
 						}
+						scaffold.PrintRerenderReport(report)
 
+						fmt.Println("Updated parameters of component", componentName)
 						return nil
 					},
 				},
+				{
+					Name:      "add-feature",
+					ArgsUsage: "<component name> <feature>",
+					Usage:     "Add a feature pack to a component of the project in the current directory",
+					Flags: []cli.Flag{
+						cli.BoolFlag{Name: "verbose", Usage: "Verbose output"},
+						cli.BoolFlag{Name: "overwrite", Usage: "Allow overwriting of target files"},
+						cli.StringFlag{Name: "parameters", Usage: "Provide template parameters"},
+					},
+					Action: func(c *cli.Context) error {
+						options.SetVerbose(c.Bool("verbose"))
+						if c.NArg() != 2 {
+							cli.ShowCommandHelp(c, "add-feature")
+							return errors.New("Invalid number of arguments")
+						}
+						componentName := c.Args().Get(0)
+						featureName := c.Args().Get(1)
+
+						var parametersOption = c.String("parameters")
+						var parameters = map[string]string{}
+						for _, parameter := range strings.Split(parametersOption, ",") {
+							split := strings.Split(parameter, "=")
+							if len(split) == 2 {
+								parameters[split[0]] = split[1]
+							}
+						}
+
+						project, err := scaffold.GetLocalProject("project")
+						if err != nil {
+							return err
+						}
+
+						featureScaffold, err := repo.GetFeature(polarisHome, polarisConfig, featureName)
+						if err != nil {
+							return err
+						}
+
+						err = scaffold.AddFeature(polarisHome, featureScaffold, project, componentName, parameters, c.Bool("overwrite"))
+						if err != nil {
+							return err
+						}
+
+						fmt.Println("Added feature", featureName, "to component", componentName)
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:      "search",
+			ArgsUsage: "<query>",
+			Usage:     "Search for projects and components to scaffold",
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "verbose", Usage: "Verbose output"},
+				cli.StringFlag{Name: "kind", Usage: "Only search for project, component or feature scaffolds"},
+				cli.StringFlag{Name: "category", Usage: "Only search within a category"},
+				cli.StringFlag{Name: "language", Usage: "Only search for a language"},
+			},
+			Action: func(c *cli.Context) error {
+				options.SetVerbose(c.Bool("verbose"))
+
+				filter := repo.SearchFilter{
+					Kind:     c.String("kind"),
+					Category: c.String("category"),
+					Language: c.String("language"),
+				}
+				results, err := repo.Search(polarisHome, polarisConfig, strings.Join(c.Args(), " "), filter)
+				if err != nil {
+					return err
+				}
+				if len(results) == 0 {
+					return errors.New("No matching scaffolds found")
+				}
+
+				for _, result := range results {
+					fmt.Println(result.Scaffold.Name, "(", result.Kind, ")", "->", result.Scaffold.Spec.Description)
+					if options.IsVerbose() {
+						fmt.Println("  score:", result.Score,
+							"category:", result.Scaffold.Spec.Category,
+							"language:", result.Scaffold.Spec.Language,
+							"tags:", strings.Join(result.Scaffold.Spec.Tags, ", "))
+					}
+				}
+
+				return nil
+			},
+		},
+		{
+			Name:  "workspace",
+			Usage: "Operate on all the projects within a workspace",
+			Subcommands: []cli.Command{
+				{
+					Name:  "list",
+					Usage: "List the projects in the workspace",
+					Flags: []cli.Flag{
+						cli.BoolFlag{Name: "verbose", Usage: "Verbose output"},
+					},
+					Action: func(c *cli.Context) error {
+						options.SetVerbose(c.Bool("verbose"))
+
+						directories, err := getWorkspaceProjects()
+						if err != nil {
+							return err
+						}
+
+						results := workspace.ForEachProject(directories, func(directory string, project *config.PolarisProject) error {
+							return nil
+						})
+						return workspace.PrintReport(results)
+					},
+				},
+				{
+					Name:  "status",
+					Usage: "Show status of every project in the workspace",
+					Flags: []cli.Flag{
+						cli.BoolFlag{Name: "verbose", Usage: "Verbose output"},
+						cli.StringFlag{Name: "namespace", Usage: "Namespace to use"},
+					},
+					Action: func(c *cli.Context) error {
+						options.SetVerbose(c.Bool("verbose"))
+
+						directories, err := getWorkspaceProjects()
+						if err != nil {
+							return err
+						}
+
+						// Connect to cluster once for all the projects
+						//
+						client, apiextensionClient, polarisClient, ns, err := cluster.ConnectToCluster()
+
+						// Check if namespace is overridden on cmdline (otherwise use the default configured one)
+						//
+						if c.String("namespace") != "" {
+							ns = c.String("namespace")
+						}
+						if err != nil {
+							return err
+						}
+
+						results := workspace.ForEachProject(directories, func(directory string, project *config.PolarisProject) error {
+							fmt.Println("==>", project.Project, "(", directory, ")")
+							return status.PrintPolarisStatus(project.Project, client, apiextensionClient, polarisClient, ns)
+						})
+						fmt.Println()
+						return workspace.PrintReport(results)
+					},
+				},
+				{
+					Name:  "diff",
+					Usage: "Show what upgrading every project in the workspace would change",
+					Flags: []cli.Flag{
+						cli.BoolFlag{Name: "verbose", Usage: "Verbose output"},
+					},
+					Action: func(c *cli.Context) error {
+						options.SetVerbose(c.Bool("verbose"))
+						return forEachWorkspaceProject(func(project *config.PolarisProject) error {
+							return upgradeProject(polarisHome, polarisConfig, project, true)
+						})
+					},
+				},
+				{
+					Name:  "upgrade",
+					Usage: "Upgrade every project in the workspace to its scaffolds as they are now",
+					Flags: []cli.Flag{
+						cli.BoolFlag{Name: "verbose", Usage: "Verbose output"},
+					},
+					Action: func(c *cli.Context) error {
+						options.SetVerbose(c.Bool("verbose"))
+						return forEachWorkspaceProject(func(project *config.PolarisProject) error {
+							return upgradeProject(polarisHome, polarisConfig, project, false)
+						})
+					},
+				},
+				{
+					Name:  "lint",
+					Usage: "Check every project in the workspace against the scaffolds it was generated from",
+					Flags: []cli.Flag{
+						cli.BoolFlag{Name: "verbose", Usage: "Verbose output"},
+					},
+					Action: func(c *cli.Context) error {
+						options.SetVerbose(c.Bool("verbose"))
+						return forEachWorkspaceProject(func(project *config.PolarisProject) error {
+							return lintProject(polarisHome, polarisConfig, project)
+						})
+					},
+				},
 			},
 		},
 	}
 
-	err = app.Run(os.Args)
+	err := app.Run(os.Args)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,15 @@
+package options
+
+var offline = false
+
+// SetOffline sets the offline flag
+//
+func SetOffline(toWhat bool) {
+	offline = toWhat
+}
+
+// IsOffline gets the offline flag
+//
+func IsOffline() bool {
+	return offline
+}
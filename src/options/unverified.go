@@ -0,0 +1,15 @@
+package options
+
+var allowUnverified = false
+
+// SetAllowUnverified sets the allow unverified flag
+//
+func SetAllowUnverified(toWhat bool) {
+	allowUnverified = toWhat
+}
+
+// IsAllowUnverified gets the allow unverified flag
+//
+func IsAllowUnverified() bool {
+	return allowUnverified
+}
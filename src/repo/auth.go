@@ -0,0 +1,292 @@
+package repo
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+	cryptossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/terminal"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
+)
+
+// repositoryAuth builds the credentials to access a repository with, from its auth settings (nil
+// when it has none). Anything which needs asking for, such as a key passphrase, is prompted for
+//
+func repositoryAuth(repoName string, repoConfig config.PolarisRepository) (transport.AuthMethod, error) {
+	auth := repoConfig.Auth
+	switch auth.Type {
+	case "":
+		return nil, nil
+
+	case "ssh-key":
+		keyPath := auth.KeyPath
+		if keyPath == "" {
+			keyPath = "~/.ssh/id_rsa"
+		}
+		keyPath, err := expandHome(keyPath)
+		if err != nil {
+			return nil, err
+		}
+		keyData, err := ioutil.ReadFile(keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read the ssh key of repository %s: %s", repoName, err)
+		}
+
+		// Only PEM keys can be decrypted, so an encrypted key in OpenSSH's own format (the default of
+		// newer versions of ssh-keygen) is turned away before the passphrase is asked for
+		//
+		passphrase := ""
+		block, _ := pem.Decode(keyData)
+		if block != nil && isEncryptedOpenSSHKey(block) {
+			return nil, fmt.Errorf("The ssh key %s of repository %s is an encrypted OpenSSH key, which can't be decrypted. Add it to ssh-agent (ssh-add %s) and use auth type ssh-agent, or convert it to PEM (ssh-keygen -p -m PEM -f %s)", keyPath, repoName, keyPath, keyPath)
+		}
+		if block != nil && x509.IsEncryptedPEMBlock(block) {
+			passphrase, err = secret(auth, fmt.Sprintf("Passphrase for %s (repository %s): ", keyPath, repoName))
+			if err != nil {
+				return nil, err
+			}
+		}
+		keys, err := ssh.NewPublicKeys(sshUser(auth), keyData, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to load the ssh key %s of repository %s: %s", keyPath, repoName, err)
+		}
+		return keys, nil
+
+	case "ssh-agent":
+		agent, err := ssh.NewSSHAgentAuth(sshUser(auth))
+		if err != nil {
+			return nil, fmt.Errorf("Unable to use the ssh agent for repository %s: %s", repoName, err)
+		}
+		return agent, nil
+
+	case "basic":
+		password, err := secret(auth, fmt.Sprintf("Password for %s (repository %s): ", auth.User, repoName))
+		if err != nil {
+			return nil, err
+		}
+		return &http.BasicAuth{Username: auth.User, Password: password}, nil
+
+	case "token":
+		if auth.PasswordEnv == "" || os.Getenv(auth.PasswordEnv) == "" {
+			return nil, fmt.Errorf("The token of repository %s must be set in an environment variable (auth passwordenv)", repoName)
+		}
+		return &http.TokenAuth{Token: os.Getenv(auth.PasswordEnv)}, nil
+
+	case "netrc":
+		return netrcAuth(repoName, repoConfig.URI)
+	}
+
+	return nil, fmt.Errorf("Unknown auth type %s for repository %s (use ssh-key, ssh-agent, basic, token or netrc)", auth.Type, repoName)
+}
+
+// isEncryptedOpenSSHKey returns whether a key is in OpenSSH's own format and encrypted, which it is
+// unless its cipher is none
+//
+func isEncryptedOpenSSHKey(block *pem.Block) bool {
+	const magic = "openssh-key-v1\x00"
+	if block.Type != "OPENSSH PRIVATE KEY" || !bytes.HasPrefix(block.Bytes, []byte(magic)) {
+		return false
+	}
+
+	var header struct {
+		CipherName string
+		Rest       []byte `ssh:"rest"`
+	}
+	if err := cryptossh.Unmarshal(block.Bytes[len(magic):], &header); err != nil {
+		return false
+	}
+	return header.CipherName != "none"
+}
+
+// sshUser is the user to connect as over ssh, git unless set
+//
+func sshUser(auth config.PolarisRepositoryAuth) string {
+	if auth.User == "" {
+		return "git"
+	}
+	return auth.User
+}
+
+// secret reads a password, token or passphrase from the environment variable named by the auth
+// settings, otherwise prompting for it when there is a terminal to prompt on
+//
+func secret(auth config.PolarisRepositoryAuth, prompt string) (string, error) {
+	if auth.PasswordEnv != "" {
+		if value, ok := os.LookupEnv(auth.PasswordEnv); ok {
+			return value, nil
+		}
+	}
+
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("%sno terminal to prompt on, set it in an environment variable (auth passwordenv)", prompt)
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	value, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+// expandHome expands a leading ~ in a path to the home folder of the current user
+//
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	currentUser, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(currentUser.HomeDir, strings.TrimPrefix(path, "~")), nil
+}
+
+// netrcAuth looks up the login and password for the host of a repository in .netrc ($NETRC, or
+// ~/.netrc), falling back to its default entry
+//
+func netrcAuth(repoName string, uri string) (transport.AuthMethod, error) {
+	endpoint, err := url.Parse(uri)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("Repository %s must have an http(s) URI to use netrc", repoName)
+	}
+
+	netrcPath := os.Getenv("NETRC")
+	if netrcPath == "" {
+		netrcPath, err = expandHome("~/.netrc")
+		if err != nil {
+			return nil, err
+		}
+	}
+	data, err := ioutil.ReadFile(netrcPath)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read %s for repository %s: %s", netrcPath, repoName, err)
+	}
+
+	auth := lookupNetrc(string(data), endpoint.Hostname())
+	if auth == nil {
+		return nil, fmt.Errorf("No entry for %s in %s for repository %s", endpoint.Hostname(), netrcPath, repoName)
+	}
+	return auth, nil
+}
+
+// lookupNetrc finds the login and password for a host in the contents of a .netrc file, from the first
+// entry for the machine, otherwise the default entry (nil when there is neither)
+//
+func lookupNetrc(data string, host string) *http.BasicAuth {
+	// Entries are a machine (or default) followed by its login, password and account, all separated
+	// by whitespace. Macros are skipped
+	//
+	var found, fallback *http.BasicAuth
+	var current *http.BasicAuth
+	scanner := &netrcScanner{data: data}
+	for {
+		token, ok := scanner.next()
+		if !ok {
+			break
+		}
+		switch token {
+		case "machine":
+			current = nil
+			if name, _ := scanner.next(); found == nil && name == host {
+				found = &http.BasicAuth{}
+				current = found
+			}
+		case "default":
+			current = nil
+			if fallback == nil {
+				fallback = &http.BasicAuth{}
+				current = fallback
+			}
+		case "login":
+			if login, _ := scanner.next(); current != nil {
+				current.Username = login
+			}
+		case "password":
+			if password, _ := scanner.next(); current != nil {
+				current.Password = password
+			}
+		case "account":
+			scanner.next()
+		case "macdef":
+			scanner.next()
+			scanner.skipMacro()
+		}
+	}
+
+	if found != nil {
+		return found
+	}
+	return fallback
+}
+
+// netrcScanner splits the contents of a .netrc file into tokens
+//
+type netrcScanner struct {
+	data     string
+	position int
+}
+
+// next returns the next token, which is either separated by whitespace or within double quotes (where
+// a backslash escapes the character after it)
+//
+func (scanner *netrcScanner) next() (string, bool) {
+	for scanner.position < len(scanner.data) && isNetrcSpace(scanner.data[scanner.position]) {
+		scanner.position++
+	}
+	if scanner.position >= len(scanner.data) {
+		return "", false
+	}
+
+	var token bytes.Buffer
+	if scanner.data[scanner.position] == '"' {
+		scanner.position++
+		for scanner.position < len(scanner.data) {
+			c := scanner.data[scanner.position]
+			scanner.position++
+			if c == '"' {
+				break
+			}
+			if c == '\\' && scanner.position < len(scanner.data) {
+				c = scanner.data[scanner.position]
+				scanner.position++
+			}
+			token.WriteByte(c)
+		}
+		return token.String(), true
+	}
+
+	for scanner.position < len(scanner.data) && !isNetrcSpace(scanner.data[scanner.position]) {
+		token.WriteByte(scanner.data[scanner.position])
+		scanner.position++
+	}
+	return token.String(), true
+}
+
+// skipMacro skips the body of a macro, which runs from the line after macdef to the next empty line
+//
+func (scanner *netrcScanner) skipMacro() {
+	end := strings.Index(scanner.data[scanner.position:], "\n\n")
+	if end < 0 {
+		scanner.position = len(scanner.data)
+		return
+	}
+	scanner.position += end + 2
+}
+
+// isNetrcSpace returns whether a character separates the tokens of a .netrc file
+//
+func isNetrcSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
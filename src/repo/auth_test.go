@@ -0,0 +1,118 @@
+package repo
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
+)
+
+func TestSSHKeyAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantErr string
+	}{
+		{"unencrypted OpenSSH key", "openssh-key", ""},
+		{"encrypted OpenSSH key", "encrypted-openssh-key", "is an encrypted OpenSSH key, which can't be decrypted. Add it to ssh-agent"},
+		{"missing key", "missing-key", "Unable to read the ssh key of repository test/repo"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repoConfig := config.PolarisRepository{
+				URI:  "git@example.com:test/repo.git",
+				Auth: config.PolarisRepositoryAuth{Type: "ssh-key", KeyPath: filepath.Join("testdata", test.key)},
+			}
+			auth, err := repositoryAuth("test/repo", repoConfig)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if keys, ok := auth.(*ssh.PublicKeys); !ok || keys.User != "git" {
+				t.Errorf("got %v, want the key as user git", auth)
+			}
+		})
+	}
+}
+
+func TestLookupNetrc(t *testing.T) {
+	tests := []struct {
+		name  string
+		netrc string
+		want  *http.BasicAuth
+	}{
+		{
+			"machine",
+			"machine other.com login a password b\nmachine example.com login user password pass\n",
+			&http.BasicAuth{Username: "user", Password: "pass"},
+		},
+		{
+			"first entry for the machine",
+			"machine example.com login first password one\nmachine example.com login second password two\n",
+			&http.BasicAuth{Username: "first", Password: "one"},
+		},
+		{
+			"default",
+			"machine other.com login a password b\ndefault login anonymous password guest\n",
+			&http.BasicAuth{Username: "anonymous", Password: "guest"},
+		},
+		{
+			"machine before default",
+			"default login anonymous password guest\nmachine example.com\n\tlogin user\n\tpassword pass\n",
+			&http.BasicAuth{Username: "user", Password: "pass"},
+		},
+		{
+			"account",
+			"machine example.com account default login user password pass\n",
+			&http.BasicAuth{Username: "user", Password: "pass"},
+		},
+		{
+			"quoted tokens",
+			"machine example.com login \"the user\" password \"pa ss\\\"word\\\\\"\n",
+			&http.BasicAuth{Username: "the user", Password: "pa ss\"word\\"},
+		},
+		{
+			"keyword as a value",
+			"machine example.com login user password macdef\n",
+			&http.BasicAuth{Username: "user", Password: "macdef"},
+		},
+		{
+			"macro",
+			"macdef init\ncd /pub\nmachine example.com login wrong password wrong\n\nmachine example.com login user password pass\n",
+			&http.BasicAuth{Username: "user", Password: "pass"},
+		},
+		{
+			"macro to the end",
+			"machine example.com login user password pass\nmacdef init\nmachine example.com login wrong password wrong\n",
+			&http.BasicAuth{Username: "user", Password: "pass"},
+		},
+		{
+			"no entry",
+			"machine other.com login a password b\n",
+			nil,
+		},
+		{
+			"empty",
+			"",
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := lookupNetrc(test.netrc, "example.com"); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("lookupNetrc = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
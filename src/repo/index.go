@@ -0,0 +1,174 @@
+package repo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+	"github.com/synthesis-labs/polaris-cli/src/options"
+	git "gopkg.in/src-d/go-git.v4"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// indexFormat is bumped whenever what is kept in an index changes, so that older indexes are rebuilt
+//
+const indexFormat = 1
+
+// scaffoldBases are the files which describe a scaffold, and so are indexed
+//
+var scaffoldBases = []string{"polaris-project.yaml", "polaris-component.yaml", "polaris-feature.yaml"}
+
+// scaffoldIndex lists the scaffolds of a repository's clone at a particular commit, in
+// POLARIS_HOME/index/<repository>.yaml
+//
+type scaffoldIndex struct {
+	Format    int
+	Commit    string
+	Scaffolds []indexedScaffold
+}
+
+// indexedScaffold is a scaffold within a repository: the file describing it, the directory it
+// is in (relative to the root of the repository) and its spec
+//
+type indexedScaffold struct {
+	Base string
+	Path string
+	Spec config.PolarisScaffoldSpec
+}
+
+// indexPath returns where the index of a repository is kept
+//
+func indexPath(polarisHome string, repoName string) string {
+	return filepath.Join(polarisHome, "index", filepath.FromSlash(repoName)+".yaml")
+}
+
+// clonedCommit returns the commit checked out in the clone of a repository (empty for a local
+// repository, which is read as it is rather than at a commit)
+//
+func clonedCommit(polarisHome string, repoName string, repoConfig config.PolarisRepository) string {
+	if repoConfig.Local != "" {
+		return ""
+	}
+	repository, err := git.PlainOpen(RepositoryPath(polarisHome, repoName, repoConfig))
+	if err != nil {
+		return ""
+	}
+	return headCommit(repository)
+}
+
+// repositoryScaffolds returns the scaffolds of a repository from its index, as long as the index is
+// of the commit which is checked out. Otherwise the repository is walked, and indexed again
+//
+func repositoryScaffolds(polarisHome string, repoName string, repoConfig config.PolarisRepository) ([]indexedScaffold, error) {
+	commit := clonedCommit(polarisHome, repoName, repoConfig)
+	if commit != "" {
+		index := scaffoldIndex{}
+		if data, err := ioutil.ReadFile(indexPath(polarisHome, repoName)); err == nil {
+			if yaml.Unmarshal(data, &index) == nil && index.Format == indexFormat && index.Commit == commit {
+				return index.Scaffolds, nil
+			}
+		}
+	}
+
+	// A failed walk is never saved as the index
+	//
+	scaffolds, err := walkScaffolds(RepositoryPath(polarisHome, repoName, repoConfig))
+	if err != nil {
+		return nil, err
+	}
+
+	// An index which can't be written just means walking again next time
+	//
+	if commit != "" {
+		err = saveIndex(polarisHome, repoName, &scaffoldIndex{Format: indexFormat, Commit: commit, Scaffolds: scaffolds})
+		if err != nil && options.IsVerbose() {
+			fmt.Println("Unable to index repository", repoName+":", err)
+		}
+	}
+	return scaffolds, nil
+}
+
+// indexRepository indexes the clone of a repository, as it has just been synchronized
+//
+func indexRepository(polarisHome string, repoName string, repoConfig config.PolarisRepository) error {
+	scaffolds, err := walkScaffolds(RepositoryPath(polarisHome, repoName, repoConfig))
+	if err != nil {
+		return err
+	}
+	commit := clonedCommit(polarisHome, repoName, repoConfig)
+	return saveIndex(polarisHome, repoName, &scaffoldIndex{Format: indexFormat, Commit: commit, Scaffolds: scaffolds})
+}
+
+// walkScaffolds finds every scaffold within a repository, skipping its .git directory. Anything which
+// can't be walked fails the walk, rather than leaving scaffolds out of the index
+//
+func walkScaffolds(repositoryPath string) ([]indexedScaffold, error) {
+	repositoryPath = filepath.Clean(repositoryPath)
+	scaffolds := []indexedScaffold{}
+	if _, err := os.Stat(repositoryPath); os.IsNotExist(err) {
+		return scaffolds, nil
+	}
+
+	err := filepath.Walk(repositoryPath, func(filename string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		filebase := filepath.Base(filename)
+		for _, base := range scaffoldBases {
+			if filebase != base {
+				continue
+			}
+
+			filenameRelativeToRepo, err := filepath.Rel(repositoryPath, filename)
+			if err != nil {
+				return err
+			}
+			scaffoldData, err := ioutil.ReadFile(filename)
+			if err != nil {
+				return err
+			}
+
+			scaffold := indexedScaffold{Base: base, Path: filepath.ToSlash(filepath.Dir(filenameRelativeToRepo))}
+			err = yaml.Unmarshal(scaffoldData, &scaffold.Spec)
+			if err != nil {
+				return fmt.Errorf("Unable to read %s: %s", filename, err)
+			}
+			scaffolds = append(scaffolds, scaffold)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(scaffolds, func(i, j int) bool {
+		if scaffolds[i].Path != scaffolds[j].Path {
+			return scaffolds[i].Path < scaffolds[j].Path
+		}
+		return scaffolds[i].Base < scaffolds[j].Base
+	})
+	return scaffolds, nil
+}
+
+// saveIndex writes the index of a repository
+//
+func saveIndex(polarisHome string, repoName string, index *scaffoldIndex) error {
+	data, err := yaml.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	path := indexPath(polarisHome, repoName)
+	err = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,68 @@
+package repo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, contents := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWalkScaffolds(t *testing.T) {
+	root, err := ioutil.TempDir("", "polaris-index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"starter/project/polaris-project.yaml":     "description: A project\n",
+		"starter/api/polaris-component.yaml":       "description: An api\n",
+		"features/logging/polaris-feature.yaml":    "description: Logging\n",
+		".git/starter/polaris-project.yaml":        "description: Not a scaffold\n",
+		"starter/project/templates/something.yaml": "a: 1\n",
+	})
+
+	scaffolds, err := walkScaffolds(root)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got := []string{}
+	for _, scaffold := range scaffolds {
+		got = append(got, scaffold.Path+" "+scaffold.Base+" "+scaffold.Spec.Description)
+	}
+	want := []string{
+		"features/logging polaris-feature.yaml Logging",
+		"starter/api polaris-component.yaml An api",
+		"starter/project polaris-project.yaml A project",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// A scaffold which can't be read fails the walk
+	//
+	writeFiles(t, root, map[string]string{"broken/polaris-component.yaml": "description: [\n"})
+	if _, err := walkScaffolds(root); err == nil {
+		t.Error("expected the walk to fail")
+	}
+}
+
+func TestWalkScaffoldsOfMissingRepository(t *testing.T) {
+	scaffolds, err := walkScaffolds(filepath.Join(os.TempDir(), "polaris-index-missing"))
+	if err != nil || len(scaffolds) != 0 {
+		t.Errorf("got %v (%v), want nothing", scaffolds, err)
+	}
+}
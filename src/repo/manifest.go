@@ -0,0 +1,174 @@
+package repo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// manifestName is the file at the root of a repository which describes it
+//
+const manifestName = "polaris-repo.yaml"
+
+// IncompatibleError is returned for a repository which needs a later polaris CLI than this one
+//
+type IncompatibleError struct {
+	Repository string
+	Required   string
+}
+
+func (err *IncompatibleError) Error() string {
+	return fmt.Sprintf("Repository %s needs polaris %s or later (this is %s)", err.Repository, err.Required, config.Version)
+}
+
+// readManifest reads the manifest at the root of a repository (nil if it has none)
+//
+func readManifest(repositoryPath string) (*config.PolarisRepositoryManifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(repositoryPath, manifestName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	manifest := config.PolarisRepositoryManifest{}
+	err = yaml.Unmarshal(data, &manifest)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read %s: %s", manifestName, err)
+	}
+	return &manifest, nil
+}
+
+// GetManifest returns the manifest of a repository (nil if it has none)
+//
+func GetManifest(polarisHome string, repoName string, repoConfig config.PolarisRepository) (*config.PolarisRepositoryManifest, error) {
+	return readManifest(RepositoryPath(polarisHome, repoName, repoConfig))
+}
+
+// CheckManifest checks that this polaris can use a repository, going by its manifest. A manifest
+// which can't be understood is only warned about, while a repository needing a later polaris
+// returns an IncompatibleError
+//
+func CheckManifest(polarisHome string, repoName string, repoConfig config.PolarisRepository) (string, error) {
+	return checkManifest(repoName, RepositoryPath(polarisHome, repoName, repoConfig))
+}
+
+// checkManifest checks the manifest of the repository at repositoryPath
+//
+func checkManifest(repoName string, repositoryPath string) (string, error) {
+	manifest, err := readManifest(repositoryPath)
+	if err != nil {
+		return err.Error(), nil
+	}
+	if manifest == nil || manifest.MinimumVersion == "" {
+		return "", nil
+	}
+
+	older, err := olderVersion(config.Version, manifest.MinimumVersion)
+	if err != nil {
+		return fmt.Sprintf("unable to check the minimum version %s in %s: %s", manifest.MinimumVersion, manifestName, err), nil
+	}
+	if older {
+		return "", &IncompatibleError{Repository: repoName, Required: manifest.MinimumVersion}
+	}
+	return "", nil
+}
+
+// parseVersion splits a version such as v1.2.3 (any -pre-release or +build suffix is ignored) into its numbers
+//
+func parseVersion(version string) ([]int, error) {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if end := strings.IndexAny(version, "-+"); end >= 0 {
+		version = version[:end]
+	}
+
+	numbers := []int{}
+	for _, part := range strings.Split(version, ".") {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return nil, fmt.Errorf("%s is not a version", version)
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, nil
+}
+
+// olderVersion checks whether version is older than minimum
+//
+func olderVersion(version string, minimum string) (bool, error) {
+	versionNumbers, err := parseVersion(version)
+	if err != nil {
+		return false, err
+	}
+	minimumNumbers, err := parseVersion(minimum)
+	if err != nil {
+		return false, err
+	}
+	return compareVersionNumbers(versionNumbers, minimumNumbers) < 0, nil
+}
+
+// compareVersionNumbers compares the numbers of two versions, returning -1, 0 or 1 as a is older than, the
+// same as or newer than b (missing numbers count as 0, so 1.2 is the same as 1.2.0)
+//
+func compareVersionNumbers(a []int, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		x, y := 0, 0
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x < y {
+			return -1
+		} else if x > y {
+			return 1
+		}
+	}
+	return 0
+}
+
+// CatalogNames returns the names of the scaffolds grouped by repository, in the order of each
+// repository's catalog, with those not in the catalog following by name
+//
+func CatalogNames(polarisHome string, polarisConfig *config.PolarisConfig, scaffolds map[string]*config.PolarisScaffold) []string {
+	catalogs := map[string]map[string]int{}
+	for repoName, repoConfig := range polarisConfig.Repositories {
+		catalogs[repoName] = map[string]int{}
+		if manifest, err := GetManifest(polarisHome, repoName, repoConfig); err == nil && manifest != nil {
+			for i, scaffoldPath := range manifest.Catalog {
+				if _, listed := catalogs[repoName][strings.Trim(scaffoldPath, "/")]; !listed {
+					catalogs[repoName][strings.Trim(scaffoldPath, "/")] = i
+				}
+			}
+		}
+	}
+
+	// Where a scaffold comes in its repository's catalog, or after all of them
+	//
+	position := func(scaffold *config.PolarisScaffold) int {
+		scaffoldPath := strings.TrimPrefix(strings.SplitN(scaffold.Name, "@", 2)[0], scaffold.Repository+"/")
+		if i, ok := catalogs[scaffold.Repository][scaffoldPath]; ok {
+			return i
+		}
+		return math.MaxInt32
+	}
+
+	names := SortedNames(scaffolds)
+	sort.SliceStable(names, func(i, j int) bool {
+		a, b := scaffolds[names[i]], scaffolds[names[j]]
+		if a.Repository != b.Repository {
+			return a.Repository < b.Repository
+		}
+		return position(a) < position(b)
+	})
+	return names
+}
@@ -0,0 +1,70 @@
+package repo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    []int
+		wantErr bool
+	}{
+		{version: "1.2.3", want: []int{1, 2, 3}},
+		{version: "v0.4", want: []int{0, 4}},
+		{version: " 2 ", want: []int{2}},
+		{version: "1.2.3-rc.1", want: []int{1, 2, 3}},
+		{version: "1.2.3+build.5", want: []int{1, 2, 3}},
+		{version: "", wantErr: true},
+		{version: "1..2", wantErr: true},
+		{version: "1.x", wantErr: true},
+		{version: "latest", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := parseVersion(test.version)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("parseVersion(%q): expected an error, got %v", test.version, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseVersion(%q) = %v (%v), want %v", test.version, got, err, test.want)
+		}
+	}
+}
+
+func TestOlderVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		minimum string
+		want    bool
+		wantErr bool
+	}{
+		{version: "1.2.3", minimum: "1.2.3", want: false},
+		{version: "1.2.2", minimum: "1.2.3", want: true},
+		{version: "1.10.0", minimum: "1.9.0", want: false},
+		{version: "1.9.0", minimum: "1.10.0", want: true},
+		{version: "2", minimum: "1.9.9", want: false},
+		{version: "1.2", minimum: "1.2.0", want: false},
+		{version: "1.2", minimum: "1.2.1", want: true},
+		{version: "v1.3.0-beta", minimum: "1.3.0", want: false},
+		{version: "dev", minimum: "1.0.0", wantErr: true},
+		{version: "1.0.0", minimum: "soon", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := olderVersion(test.version, test.minimum)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("olderVersion(%q, %q): expected an error", test.version, test.minimum)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("olderVersion(%q, %q) = %t (%v), want %t", test.version, test.minimum, got, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package repo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// SyncMetadata records the synchronization of a repository, in POLARIS_HOME/sync/<repository>.yaml.
+// LastSync is when it was last synchronized successfully, and Ref and Commit what that resolved, while
+// Result and Error are the outcome of the last attempt (which may have failed since)
+//
+type SyncMetadata struct {
+	LastSync    time.Time
+	LastAttempt time.Time
+	Ref         string `yaml:",omitempty"`
+	Commit      string `yaml:",omitempty"`
+	Result      string `yaml:",omitempty"`
+	Error       string `yaml:",omitempty"`
+}
+
+// syncMetadataPath returns where the sync metadata of a repository is kept
+//
+func syncMetadataPath(polarisHome string, repoName string) string {
+	return filepath.Join(polarisHome, "sync", filepath.FromSlash(repoName)+".yaml")
+}
+
+// GetSyncMetadata reads the sync metadata of a repository (empty if it has never been synced)
+//
+func GetSyncMetadata(polarisHome string, repoName string) (*SyncMetadata, error) {
+	metadata := SyncMetadata{}
+	data, err := ioutil.ReadFile(syncMetadataPath(polarisHome, repoName))
+	if os.IsNotExist(err) {
+		return &metadata, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = yaml.Unmarshal(data, &metadata)
+	if err != nil {
+		return nil, err
+	}
+	return &metadata, nil
+}
+
+// saveSyncMetadata writes the sync metadata of a repository
+//
+func saveSyncMetadata(polarisHome string, repoName string, metadata *SyncMetadata) error {
+	data, err := yaml.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	metadataPath := syncMetadataPath(polarisHome, repoName)
+	err = os.MkdirAll(filepath.Dir(metadataPath), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(metadataPath, data, 0644)
+}
+
+// syncInterval returns how often a repository is synchronized automatically, where a negative
+// interval means never
+//
+func syncInterval(repoConfig config.PolarisRepository) time.Duration {
+	if repoConfig.SyncInterval == 0 {
+		return config.DefaultSyncInterval
+	}
+	return repoConfig.SyncInterval
+}
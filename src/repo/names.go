@@ -0,0 +1,78 @@
+package repo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+)
+
+// repositoryByNameOrAlias finds a repository by its name or alias
+//
+func repositoryByNameOrAlias(polarisConfig *config.PolarisConfig, nameOrAlias string) (string, bool) {
+	if _, ok := polarisConfig.Repositories[nameOrAlias]; ok {
+		return nameOrAlias, true
+	}
+	for repoName, repoConfig := range polarisConfig.Repositories {
+		if repoConfig.Alias != "" && repoConfig.Alias == nameOrAlias {
+			return repoName, true
+		}
+	}
+	return "", false
+}
+
+// resolveScaffoldName turns a reference to a scaffold into its full name (<repository>/<path>), keeping
+// any @version. A reference is either <repository or alias>:<path>, a full name, or just a path. A path
+// is looked for in every repository, and if several have it the one with the highest priority is used
+// (then the first by name), with a warning
+//
+func resolveScaffoldName(polarisHome string, polarisConfig *config.PolarisConfig, baseName string, reference string) (string, error) {
+	name, version := splitVersion(reference)
+	suffix := ""
+	if version != "" {
+		suffix = "@" + version
+	}
+
+	if colon := strings.Index(name, ":"); colon >= 0 {
+		repoName, ok := repositoryByNameOrAlias(polarisConfig, name[:colon])
+		if !ok {
+			return "", fmt.Errorf("Unable to find repository or alias %s (in %s)", name[:colon], reference)
+		}
+		return repoName + "/" + strings.Trim(name[colon+1:], "/") + suffix, nil
+	}
+
+	if _, _, err := repositoryForScaffold(polarisConfig, name); err == nil {
+		return reference, nil
+	}
+
+	providers := []string{}
+	for repoName, repoConfig := range polarisConfig.Repositories {
+		scaffolds, err := repositoryScaffolds(polarisHome, repoName, repoConfig)
+		if err != nil {
+			return "", err
+		}
+		for _, scaffold := range scaffolds {
+			if scaffold.Base == baseName && scaffold.Path == strings.Trim(name, "/") {
+				providers = append(providers, repoName)
+			}
+		}
+	}
+	if len(providers) == 0 {
+		return reference, nil
+	}
+
+	sort.Slice(providers, func(i, j int) bool {
+		priorityI := polarisConfig.Repositories[providers[i]].Priority
+		priorityJ := polarisConfig.Repositories[providers[j]].Priority
+		if priorityI != priorityJ {
+			return priorityI > priorityJ
+		}
+		return providers[i] < providers[j]
+	})
+	if len(providers) > 1 {
+		fmt.Printf("Warning: %s is in repositories %s, using %s:%s (set a priority, or use <repository>:%s)\n",
+			name, strings.Join(providers, ", "), providers[0], name, name)
+	}
+	return providers[0] + "/" + strings.Trim(name, "/") + suffix, nil
+}
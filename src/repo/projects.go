@@ -1,56 +1,65 @@
 package repo
 
 import (
-	"fmt"
-	"io/ioutil"
-	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/synthesis-labs/polaris-cli/src/config"
-	yaml "gopkg.in/yaml.v2"
 )
 
+// searchRepoForBase finds the scaffolds described by a baseName file in each repository, from the
+// index of its clone or by reading a local repository in place
+//
 func searchRepoForBase(polarisHome string, polarisConfig *config.PolarisConfig, baseName string, matchingNames ...string) (map[string]*config.PolarisScaffold, error) {
-	reposHome := filepath.Clean(filepath.Join(polarisHome, "repos"))
 	result := map[string]*config.PolarisScaffold{}
-	err := filepath.Walk(reposHome, func(filename string, info os.FileInfo, err error) error {
-		filebase := filepath.Base(filename)
-		if filebase == baseName {
-
-			filenameRelativeToRepo, err := filepath.Rel(reposHome, filename)
-			if err != nil {
-				return err
+	for repoName, repoConfig := range polarisConfig.Repositories {
+		// Only the repositories the names could be in need looking at
+		//
+		if len(matchingNames) > 0 {
+			candidate := false
+			for _, matching := range matchingNames {
+				if strings.HasPrefix(matching, repoName+"/") {
+					candidate = true
+				}
 			}
-
-			scaffoldName := filepath.ToSlash(filepath.Dir(filenameRelativeToRepo))
-			scaffoldData, err := ioutil.ReadFile(filename)
-			if err != nil {
-				return err
+			if !candidate {
+				continue
 			}
+		}
 
-			scaffold := config.PolarisScaffold{}
-			err = yaml.Unmarshal(scaffoldData, &scaffold.Spec)
-			if err != nil {
-				return err
+		scaffolds, err := repositoryScaffolds(polarisHome, repoName, repoConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		repositoryPath := filepath.Clean(RepositoryPath(polarisHome, repoName, repoConfig))
+		commit := clonedCommit(polarisHome, repoName, repoConfig)
+		for _, indexed := range scaffolds {
+			if indexed.Base != baseName {
+				continue
 			}
 
-			scaffold.Name = scaffoldName
-			scaffold.LocalPath = filepath.Dir(filename)
+			scaffoldName := repoName + "/" + indexed.Path
 			found := len(matchingNames) == 0
 			for _, matching := range matchingNames {
 				if matching == scaffoldName {
 					found = true
 				}
 			}
-			if found {
-				result[scaffoldName] = &scaffold
+			if !found {
+				continue
 			}
-		}
 
-		return nil
-	})
-	if err != nil {
-		return nil, err
+			result[scaffoldName] = &config.PolarisScaffold{
+				Spec:           indexed.Spec,
+				Name:           scaffoldName,
+				LocalPath:      filepath.Join(repositoryPath, filepath.FromSlash(indexed.Path)),
+				Repository:     repoName,
+				RepositoryPath: repositoryPath,
+				Commit:         commit,
+				Limits:         repoConfig.Limits.WithDefaults(),
+			}
+		}
 	}
 
 	return result, nil
@@ -65,6 +74,17 @@ func ListProjects(polarisHome string, polarisConfig *config.PolarisConfig, match
 // GetProject returns a particular project
 //
 func GetProject(polarisHome string, polarisConfig *config.PolarisConfig, projectName string) (*config.PolarisScaffold, error) {
+	projectName, err := resolveScaffoldName(polarisHome, polarisConfig, "polaris-project.yaml", projectName)
+	if err != nil {
+		return nil, err
+	}
+
+	// A versioned reference (name@version) is rendered from that revision of the repository
+	//
+	if baseName, version := splitVersion(projectName); version != "" {
+		return getScaffoldVersion(polarisHome, polarisConfig, "polaris-project.yaml", baseName, version)
+	}
+
 	projects, err := ListProjects(polarisHome, polarisConfig, projectName)
 
 	if err != nil {
@@ -72,7 +92,11 @@ func GetProject(polarisHome string, polarisConfig *config.PolarisConfig, project
 	}
 
 	if len(projects) != 1 {
-		return nil, fmt.Errorf("Unable to find project with name %s", projectName)
+		all, err := ListProjects(polarisHome, polarisConfig)
+		if err != nil {
+			return nil, err
+		}
+		return nil, notFoundError("project", projectName, all)
 	}
 
 	return projects[projectName], nil
@@ -87,6 +111,17 @@ func ListComponents(polarisHome string, polarisConfig *config.PolarisConfig, mat
 // GetComponent returns a particular component
 //
 func GetComponent(polarisHome string, polarisConfig *config.PolarisConfig, componentName string) (*config.PolarisScaffold, error) {
+	componentName, err := resolveScaffoldName(polarisHome, polarisConfig, "polaris-component.yaml", componentName)
+	if err != nil {
+		return nil, err
+	}
+
+	// A versioned reference (name@version) is rendered from that revision of the repository
+	//
+	if baseName, version := splitVersion(componentName); version != "" {
+		return getScaffoldVersion(polarisHome, polarisConfig, "polaris-component.yaml", baseName, version)
+	}
+
 	components, err := ListComponents(polarisHome, polarisConfig, componentName)
 
 	if err != nil {
@@ -94,8 +129,49 @@ func GetComponent(polarisHome string, polarisConfig *config.PolarisConfig, compo
 	}
 
 	if len(components) != 1 {
-		return nil, fmt.Errorf("Unable to find component with name %s", componentName)
+		all, err := ListComponents(polarisHome, polarisConfig)
+		if err != nil {
+			return nil, err
+		}
+		return nil, notFoundError("component", componentName, all)
 	}
 
 	return components[componentName], nil
 }
+
+// ListFeatures returns the list of available feature packs in all repositories
+//
+func ListFeatures(polarisHome string, polarisConfig *config.PolarisConfig, matchingNames ...string) (map[string]*config.PolarisScaffold, error) {
+	return searchRepoForBase(polarisHome, polarisConfig, "polaris-feature.yaml", matchingNames...)
+}
+
+// GetFeature returns a particular feature pack
+//
+func GetFeature(polarisHome string, polarisConfig *config.PolarisConfig, featureName string) (*config.PolarisScaffold, error) {
+	featureName, err := resolveScaffoldName(polarisHome, polarisConfig, "polaris-feature.yaml", featureName)
+	if err != nil {
+		return nil, err
+	}
+
+	// A versioned reference (name@version) is rendered from that revision of the repository
+	//
+	if baseName, version := splitVersion(featureName); version != "" {
+		return getScaffoldVersion(polarisHome, polarisConfig, "polaris-feature.yaml", baseName, version)
+	}
+
+	features, err := ListFeatures(polarisHome, polarisConfig, featureName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(features) != 1 {
+		all, err := ListFeatures(polarisHome, polarisConfig)
+		if err != nil {
+			return nil, err
+		}
+		return nil, notFoundError("feature", featureName, all)
+	}
+
+	return features[featureName], nil
+}
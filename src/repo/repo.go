@@ -1,18 +1,52 @@
 package repo
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"text/tabwriter"
 	"time"
 
 	"github.com/synthesis-labs/polaris-cli/src/config"
+	"github.com/synthesis-labs/polaris-cli/src/options"
 	git "gopkg.in/src-d/go-git.v4"
+	gitconfig "gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 )
 
-// SynchronizeRepositories synchronizes repositories to local /.polaris folder
+// defaultSyncConcurrency is how many repositories are synchronized at once, unless configured otherwise
 //
-func SynchronizeRepositories(polarisHome string, polarisConfig *config.PolarisConfig, force bool, onlyThese ...string) error {
+const defaultSyncConcurrency = 4
+
+// SyncResult is the outcome of synchronizing a single repository: updated, unchanged or failed
+//
+type SyncResult struct {
+	Repository string
+	Status     string
+	Detail     string
+	Commit     string
+	Err        error
+}
+
+// RepositoryPath returns where the scaffolds of a repository are read from: its directory for a local
+// repository, otherwise its clone in POLARIS_HOME/repos
+//
+func RepositoryPath(polarisHome string, repoName string, repoConfig config.PolarisRepository) string {
+	if repoConfig.Local != "" {
+		return repoConfig.Local
+	}
+	return filepath.Join(polarisHome, "repos", filepath.FromSlash(repoName))
+}
+
+// SynchronizeRepositories synchronizes repositories to local /.polaris folder, several at a time. A
+// repository which fails to synchronize doesn't stop the others; its failure is in its result
+//
+func SynchronizeRepositories(polarisHome string, polarisConfig *config.PolarisConfig, force bool, onlyThese ...string) ([]SyncResult, error) {
 	reposHome := fmt.Sprintf("%s/repos", polarisHome)
 
 	// Remove and recreate the repos folder if force is set
@@ -23,7 +57,14 @@ func SynchronizeRepositories(polarisHome string, polarisConfig *config.PolarisCo
 		os.MkdirAll(reposHome, os.ModePerm)
 	}
 
+	repoNames := []string{}
 	for repoName, repoConfig := range polarisConfig.Repositories {
+		// Local repositories are read in place, so there is nothing to sync
+		//
+		if repoConfig.Local != "" {
+			continue
+		}
+
 		// If we are being told to filter
 		//
 		if len(onlyThese) > 0 {
@@ -37,70 +78,403 @@ func SynchronizeRepositories(polarisHome string, polarisConfig *config.PolarisCo
 				continue
 			}
 		}
+		repoNames = append(repoNames, repoName)
+	}
+	sort.Strings(repoNames)
 
-		fmt.Println("Syncing repository", repoName, repoConfig.URI, repoConfig.Ref)
+	concurrency := polarisConfig.SyncConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultSyncConcurrency
+	}
 
-		_, err := git.PlainClone(fmt.Sprintf("%s/repos/%s", polarisHome, repoName), false, &git.CloneOptions{
-			URL:           repoConfig.URI,
-			SingleBranch:  true,
-			ReferenceName: plumbing.ReferenceName(repoConfig.Ref),
-			Progress:      os.Stdout,
-		})
-		if err == git.ErrRepositoryAlreadyExists {
-			fmt.Println(" .. already exists")
-			repository, err := git.PlainOpen(fmt.Sprintf("%s/repos/%s", polarisHome, repoName))
-			if err != nil {
-				return err
-			}
-			fmt.Println("Pulling repository", repoName, repoConfig.URI, repoConfig.Ref)
+	// Credentials are worked out one repository at a time first, as they may need prompting for
+	//
+	results := make([]SyncResult, len(repoNames))
+	auths := make([]transport.AuthMethod, len(repoNames))
+	for i, repoName := range repoNames {
+		auth, err := repositoryAuth(repoName, polarisConfig.Repositories[repoName])
+		if err != nil {
+			results[i] = SyncResult{Repository: repoName, Status: "failed", Err: err}
+		}
+		auths[i] = auth
+	}
+
+	// Each repository's output is held back until it is done, so that it isn't interleaved with the others. The
+	// progress of git itself is only included when verbose
+	//
+	slots := make(chan struct{}, concurrency)
+	var outputLock sync.Mutex
+	var wait sync.WaitGroup
+	for i, repoName := range repoNames {
+		if results[i].Err != nil {
+			continue
+		}
+		wait.Add(1)
+		go func(i int, repoName string) {
+			defer wait.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
 
-			worktree, err := repository.Worktree()
-			if err != nil {
-				return err
+			var output bytes.Buffer
+			results[i] = synchronizeRepository(polarisHome, repoName, polarisConfig.Repositories[repoName], auths[i], &output)
+
+			outputLock.Lock()
+			os.Stdout.Write(output.Bytes())
+			outputLock.Unlock()
+		}(i, repoName)
+	}
+	wait.Wait()
+
+	// Record the outcome for each repository, keeping what the last successful sync resolved to
+	//
+	for _, result := range results {
+		metadata, err := GetSyncMetadata(polarisHome, result.Repository)
+		if err != nil {
+			return nil, err
+		}
+		metadata.LastAttempt = time.Now()
+		metadata.Result = result.Status
+		metadata.Error = ""
+		if result.Err != nil {
+			metadata.Error = result.Err.Error()
+		} else {
+			// A repository which can't be indexed now is indexed when it is next looked at
+			//
+			err = indexRepository(polarisHome, result.Repository, polarisConfig.Repositories[result.Repository])
+			if err != nil && options.IsVerbose() {
+				fmt.Println("Unable to index repository", result.Repository+":", err)
 			}
-			err = worktree.Pull(&git.PullOptions{RemoteName: "origin", ReferenceName: plumbing.ReferenceName(repoConfig.Ref)})
-			if err == git.NoErrAlreadyUpToDate {
-				fmt.Println(" .. already up to date")
-			} else if err != nil {
-				return err
+
+			metadata.LastSync = metadata.LastAttempt
+			metadata.Commit = result.Commit
+			metadata.Ref = polarisConfig.Repositories[result.Repository].Ref
+		}
+
+		err = saveSyncMetadata(polarisHome, result.Repository, metadata)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// headCommit returns the hash of the commit checked out in a repository
+//
+func headCommit(repository *git.Repository) string {
+	head, err := repository.Head()
+	if err != nil {
+		return ""
+	}
+	return head.Hash().String()
+}
+
+// shortHash shortens a commit hash for display
+//
+func shortHash(hash string) string {
+	if hash == "" {
+		return "unknown"
+	}
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+// progress returns where the progress of git goes: to the repository's output when verbose, otherwise nowhere
+//
+func progress(output io.Writer) io.Writer {
+	if options.IsVerbose() {
+		return output
+	}
+	return nil
+}
+
+// synchronizeRepository updates the clone of a repository, writing its progress to output. A new
+// revision whose manifest needs a later polaris is refused, and the clone put back as it was
+//
+func synchronizeRepository(polarisHome string, repoName string, repoConfig config.PolarisRepository, auth transport.AuthMethod, output io.Writer) SyncResult {
+	localPath := RepositoryPath(polarisHome, repoName, repoConfig)
+	before := ""
+	if repository, err := git.PlainOpen(localPath); err == nil {
+		before = headCommit(repository)
+	}
+
+	result := updateRepository(polarisHome, repoName, repoConfig, auth, output)
+	if result.Err != nil {
+		return result
+	}
+
+	// What is checked out must be signed by a trusted key (even if it hasn't changed, as the keys may
+	// have), otherwise it is refused unless unverified content is allowed
+	//
+	signedBy, err := verifyRepository(localPath, repoName, repoConfig)
+	if err != nil && options.IsAllowUnverified() {
+		fmt.Fprintln(output, "Warning:", err)
+		result.Detail = fmt.Sprintf("%s (unverified: %s)", result.Detail, err)
+	} else if err != nil {
+		fmt.Fprintln(output, " .. refused:", err)
+
+		// Nothing unverified is kept, so if what the clone was at before isn't trusted either it goes
+		//
+		rollbackRepository(localPath, before)
+		if _, verifyErr := verifyRepository(localPath, repoName, repoConfig); verifyErr != nil {
+			os.RemoveAll(localPath)
+		}
+		return SyncResult{Repository: repoName, Status: "failed", Err: &UnverifiedError{Err: err}}
+	} else if signedBy != "" {
+		result.Detail = fmt.Sprintf("%s, signed by %s", result.Detail, signedBy)
+	}
+	if result.Status == "unchanged" {
+		return result
+	}
+
+	warning, err := checkManifest(repoName, localPath)
+	if warning != "" {
+		fmt.Fprintln(output, "Warning:", warning)
+		result.Detail = fmt.Sprintf("%s (%s)", result.Detail, warning)
+	}
+	if err != nil {
+		fmt.Fprintln(output, " .. refused:", err)
+		rollbackRepository(localPath, before)
+		return SyncResult{Repository: repoName, Status: "failed", Err: err}
+	}
+	return result
+}
+
+// rollbackRepository puts a clone back at the commit it was at, or removes it if it wasn't cloned before
+//
+func rollbackRepository(localPath string, commit string) {
+	if commit != "" {
+		if repository, err := git.PlainOpen(localPath); err == nil {
+			if worktree, err := repository.Worktree(); err == nil {
+				err = worktree.Reset(&git.ResetOptions{Commit: plumbing.NewHash(commit), Mode: git.HardReset})
+				if err == nil {
+					return
+				}
 			}
+		}
+	}
+	os.RemoveAll(localPath)
+}
 
+// updateRepository clones a repository, or pulls it if it has been cloned before, writing
+// its progress to output
+//
+func updateRepository(polarisHome string, repoName string, repoConfig config.PolarisRepository, auth transport.AuthMethod, output io.Writer) SyncResult {
+	result := SyncResult{Repository: repoName}
+	fail := func(err error) SyncResult {
+		fmt.Fprintln(output, " .. failed:", err)
+		result.Status = "failed"
+		result.Err = err
+		return result
+	}
+
+	fmt.Fprintln(output, "Syncing repository", repoName, repoConfig.URI, repoConfig.Ref)
+	localPath := RepositoryPath(polarisHome, repoName, repoConfig)
+	if repoConfig.IsPinned() {
+		return synchronizePinnedRepository(localPath, repoName, repoConfig, auth, output)
+	}
+
+	// A clone which isn't on the branch (it was pinned, or followed another branch) is cloned afresh
+	//
+	if repository, err := git.PlainOpen(localPath); err == nil {
+		if head, err := repository.Head(); err != nil || head.Name().String() != repoConfig.Ref {
+			fmt.Fprintln(output, " .. not on", repoConfig.Ref+", cloning again")
+			os.RemoveAll(localPath)
+		}
+	}
+	_, statErr := os.Stat(localPath)
+
+	repository, err := git.PlainClone(localPath, false, &git.CloneOptions{
+		URL:           repoConfig.URI,
+		Auth:          auth,
+		SingleBranch:  true,
+		ReferenceName: plumbing.ReferenceName(repoConfig.Ref),
+		Progress:      progress(output),
+	})
+	if err == git.ErrRepositoryAlreadyExists {
+		fmt.Fprintln(output, " .. already exists")
+		repository, err := git.PlainOpen(localPath)
+		if err != nil {
+			return fail(err)
+		}
+		fmt.Fprintln(output, "Pulling repository", repoName, repoConfig.URI, repoConfig.Ref)
+
+		worktree, err := repository.Worktree()
+		if err != nil {
+			return fail(err)
+		}
+		before := headCommit(repository)
+		err = worktree.Pull(&git.PullOptions{RemoteName: "origin", Auth: auth, ReferenceName: plumbing.ReferenceName(repoConfig.Ref), Progress: progress(output)})
+		if err == git.NoErrAlreadyUpToDate {
+			fmt.Fprintln(output, " .. already up to date")
+			result.Status = "unchanged"
+			result.Detail = fmt.Sprintf("at %s", shortHash(before))
+			result.Commit = before
+			return result
 		} else if err != nil {
-			return err
-		} else {
-			fmt.Println("Cloned repository", repoName, repoConfig.URI, repoConfig.Ref)
+			return fail(err)
+		}
+
+		result.Status = "updated"
+		result.Commit = headCommit(repository)
+		result.Detail = fmt.Sprintf("%s -> %s", shortHash(before), shortHash(result.Commit))
+		return result
+
+	} else if err != nil {
+		// Don't leave a half cloned repository behind
+		//
+		if os.IsNotExist(statErr) {
+			os.RemoveAll(localPath)
 		}
+		return fail(err)
 	}
 
-	// Update the "lastsync" file to remember this sync
-	//
-	file, err := os.OpenFile(fmt.Sprintf("%s/.lastsync", reposHome), os.O_RDWR|os.O_CREATE, 0666)
+	fmt.Fprintln(output, "Cloned repository", repoName, repoConfig.URI, repoConfig.Ref)
+	result.Status = "updated"
+	result.Commit = headCommit(repository)
+	result.Detail = fmt.Sprintf("cloned at %s", shortHash(result.Commit))
+	return result
+}
+
+// synchronizePinnedRepository checks out exactly the tag or commit a repository is pinned to. All
+// branches and tags are cloned, and only fetched again when the pin can't be found locally
+//
+func synchronizePinnedRepository(localPath string, repoName string, repoConfig config.PolarisRepository, auth transport.AuthMethod, output io.Writer) SyncResult {
+	result := SyncResult{Repository: repoName}
+	fail := func(err error) SyncResult {
+		fmt.Fprintln(output, " .. failed:", err)
+		result.Status = "failed"
+		result.Err = err
+		return result
+	}
+
+	cloned := false
+	repository, err := git.PlainOpen(localPath)
+	if err == git.ErrRepositoryNotExists {
+		repository, err = git.PlainClone(localPath, false, &git.CloneOptions{
+			URL:        repoConfig.URI,
+			Auth:       auth,
+			NoCheckout: true,
+			Progress:   progress(output),
+		})
+		if err != nil {
+			os.RemoveAll(localPath)
+			return fail(err)
+		}
+		cloned = true
+	} else if err != nil {
+		return fail(err)
+	}
+
+	hash, err := repository.ResolveRevision(plumbing.Revision(repoConfig.Ref))
 	if err != nil {
-		return err
+		fmt.Fprintln(output, "Fetching repository", repoName, repoConfig.URI, "to find", repoConfig.Ref)
+		err = repository.Fetch(&git.FetchOptions{
+			RemoteName: "origin",
+			Auth:       auth,
+			RefSpecs:   []gitconfig.RefSpec{"+refs/heads/*:refs/remotes/origin/*", "+refs/tags/*:refs/tags/*"},
+			Tags:       git.AllTags,
+			Progress:   progress(output),
+		})
+		if err != nil && err != git.NoErrAlreadyUpToDate {
+			return fail(err)
+		}
+		hash, err = repository.ResolveRevision(plumbing.Revision(repoConfig.Ref))
+		if err != nil {
+			return fail(fmt.Errorf("Unable to find %s: %s", repoConfig.Ref, err))
+		}
+	}
+
+	before := headCommit(repository)
+	result.Commit = hash.String()
+	if !cloned && before == result.Commit {
+		fmt.Fprintln(output, " .. already at", repoConfig.Ref)
+		result.Status = "unchanged"
+		result.Detail = fmt.Sprintf("pinned at %s", shortHash(result.Commit))
+		return result
 	}
-	_, err = file.Write([]byte{0x00})
+
+	worktree, err := repository.Worktree()
 	if err != nil {
-		return err
+		return fail(err)
 	}
-	err = file.Close()
+	err = worktree.Checkout(&git.CheckoutOptions{Hash: *hash, Force: true})
 	if err != nil {
-		return err
+		return fail(err)
 	}
 
-	return nil
+	fmt.Fprintln(output, "Checked out repository", repoName, repoConfig.URI, repoConfig.Ref)
+	result.Status = "updated"
+	if cloned {
+		result.Detail = fmt.Sprintf("cloned, pinned at %s", shortHash(result.Commit))
+	} else {
+		result.Detail = fmt.Sprintf("%s -> %s (pinned)", shortHash(before), shortHash(result.Commit))
+	}
+	return result
 }
 
-// NeedSynchronizeRepositories checks whether we need to resync based on when last we synced
+// PrintSyncReport prints a table of what happened to each repository, returning an error if any of them failed
 //
-func NeedSynchronizeRepositories(polarisHome string, polarisConfig *config.PolarisConfig) (bool, error) {
-	reposHome := fmt.Sprintf("%s/repos", polarisHome)
-	fileinfo, err := os.Stat(fmt.Sprintf("%s/.lastsync", reposHome))
-	if os.IsNotExist(err) {
-		return true, nil
-	} else if err != nil {
-		return false, err
+func PrintSyncReport(results []SyncResult) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, "REPOSITORY\tSTATUS\tDETAIL\t")
+
+	failed := 0
+	for _, result := range results {
+		detail := result.Detail
+		if result.Err != nil {
+			detail = result.Err.Error()
+			failed++
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t\n", result.Repository, result.Status, detail)
 	}
+	writer.Flush()
 
-	sinceLastSync := time.Since(fileinfo.ModTime())
-	return sinceLastSync > 24*time.Hour, nil
+	if failed > 0 {
+		return fmt.Errorf("%d of %d repositories failed to sync", failed, len(results))
+	}
+	return nil
+}
+
+// NeedSynchronizeRepositories returns the repositories which are due to be synchronized, being those
+// which have not been cloned yet, were last synchronized longer ago than their sync interval, or
+// are pinned to a different tag or commit than they were last synchronized at
+//
+func NeedSynchronizeRepositories(polarisHome string, polarisConfig *config.PolarisConfig) ([]string, error) {
+	due := []string{}
+	for repoName, repoConfig := range polarisConfig.Repositories {
+		if repoConfig.Local != "" {
+			continue
+		}
+		if _, err := os.Stat(RepositoryPath(polarisHome, repoName, repoConfig)); os.IsNotExist(err) {
+			due = append(due, repoName)
+			continue
+		}
+
+		metadata, err := GetSyncMetadata(polarisHome, repoName)
+		if err != nil {
+			return nil, err
+		}
+
+		// A pinned repository can't change, unless it is pinned to something else
+		//
+		if repoConfig.IsPinned() {
+			if metadata.Ref != repoConfig.Ref {
+				due = append(due, repoName)
+			}
+			continue
+		}
+
+		interval := syncInterval(repoConfig)
+		if interval < 0 {
+			continue
+		}
+		if time.Since(metadata.LastSync) > interval {
+			due = append(due, repoName)
+		}
+	}
+	sort.Strings(due)
+	return due, nil
 }
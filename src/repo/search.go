@@ -0,0 +1,279 @@
+package repo
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+)
+
+// SearchResult holds a scaffold matching a search, along with how well it matched
+//
+type SearchResult struct {
+	Kind     string
+	Scaffold *config.PolarisScaffold
+	Score    int
+}
+
+// SearchFilter restricts a search to scaffolds of a particular kind, category or language
+// (empty fields match everything)
+//
+type SearchFilter struct {
+	Kind     string
+	Category string
+	Language string
+}
+
+// SortedNames returns the names of the scaffolds in alphabetical order
+//
+func SortedNames(scaffolds map[string]*config.PolarisScaffold) []string {
+	names := []string{}
+	for name := range scaffolds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Search finds the projects, components and feature packs matching the query, best matches first. Every
+// word of the query must match the name, description, tags, category or language
+//
+func Search(polarisHome string, polarisConfig *config.PolarisConfig, query string, filter SearchFilter) ([]SearchResult, error) {
+	terms := strings.Fields(strings.ToLower(query))
+
+	kinds := map[string]func(string, *config.PolarisConfig, ...string) (map[string]*config.PolarisScaffold, error){
+		"project":   ListProjects,
+		"component": ListComponents,
+		"feature":   ListFeatures,
+	}
+
+	results := []SearchResult{}
+	for kind, list := range kinds {
+		if filter.Kind != "" && filter.Kind != kind {
+			continue
+		}
+
+		scaffolds, err := list(polarisHome, polarisConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, scaffold := range scaffolds {
+			if filter.Category != "" && !strings.EqualFold(filter.Category, scaffold.Spec.Category) {
+				continue
+			}
+			if filter.Language != "" && !strings.EqualFold(filter.Language, scaffold.Spec.Language) {
+				continue
+			}
+
+			score := scoreScaffold(scaffold, terms)
+			if score > 0 {
+				results = append(results, SearchResult{Kind: kind, Scaffold: scaffold, Score: score})
+			}
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Scaffold.Name < results[j].Scaffold.Name
+	})
+
+	return results, nil
+}
+
+// scoreScaffold scores how well a scaffold matches all of the terms (0 when any term doesn't match)
+//
+func scoreScaffold(scaffold *config.PolarisScaffold, terms []string) int {
+	// An empty query matches everything equally
+	//
+	if len(terms) == 0 {
+		return 1
+	}
+
+	name := strings.ToLower(scaffold.Name)
+	nameWords := strings.FieldsFunc(name, isSeparator)
+	descriptionWords := strings.FieldsFunc(strings.ToLower(scaffold.Spec.Description), isSeparator)
+
+	total := 0
+	for _, term := range terms {
+		score := 0
+
+		// Name matches weigh the most
+		//
+		switch {
+		case path.Base(name) == term:
+			score += 100
+		case strings.Contains(name, term):
+			score += 50
+		case matchesAnyWord(term, nameWords, 1):
+			score += 25
+		case abbreviates(term, path.Base(name)):
+			score += 10
+		}
+
+		// Then tags
+		//
+		for _, tag := range scaffold.Spec.Tags {
+			tag = strings.ToLower(tag)
+			switch {
+			case tag == term:
+				score += 40
+			case strings.Contains(tag, term):
+				score += 20
+			case levenshtein(term, tag) <= 1:
+				score += 10
+			}
+		}
+
+		// Then category, language and description
+		//
+		if strings.ToLower(scaffold.Spec.Category) == term {
+			score += 30
+		}
+		if strings.ToLower(scaffold.Spec.Language) == term {
+			score += 30
+		}
+		if matchesAnyWord(term, descriptionWords, 0) {
+			score += 15
+		} else if matchesAnyWord(term, descriptionWords, 1) {
+			score += 5
+		}
+
+		if score == 0 {
+			return 0
+		}
+		total += score
+	}
+
+	return total
+}
+
+// suggestNames returns the candidates which look like what was probably meant by name
+//
+func suggestNames(name string, candidates []string) []string {
+	type suggestion struct {
+		name     string
+		distance int
+	}
+
+	suggestions := []suggestion{}
+	base := path.Base(name)
+	for _, candidate := range candidates {
+		distance := levenshtein(name, candidate)
+		if baseDistance := levenshtein(base, path.Base(candidate)); baseDistance < distance {
+			distance = baseDistance
+		}
+		if distance <= len(base)/3+1 || strings.Contains(candidate, base) {
+			suggestions = append(suggestions, suggestion{name: candidate, distance: distance})
+		}
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].distance != suggestions[j].distance {
+			return suggestions[i].distance < suggestions[j].distance
+		}
+		return suggestions[i].name < suggestions[j].name
+	})
+
+	result := []string{}
+	for i := 0; i < len(suggestions) && i < 3; i++ {
+		result = append(result, suggestions[i].name)
+	}
+	return result
+}
+
+// notFoundError builds the error for a scaffold that couldn't be found, with suggestions if there are any
+//
+func notFoundError(kind string, name string, scaffolds map[string]*config.PolarisScaffold) error {
+	suggestions := suggestNames(name, SortedNames(scaffolds))
+	if len(suggestions) == 0 {
+		return fmt.Errorf("Unable to find %s with name %s", kind, name)
+	}
+	return fmt.Errorf("Unable to find %s with name %s, did you mean: %s?", kind, name, strings.Join(suggestions, ", "))
+}
+
+func isSeparator(r rune) bool {
+	return r == '/' || r == '-' || r == '_' || r == '.' || r == ' ' || r == ',' || r == '(' || r == ')'
+}
+
+// matchesAnyWord checks whether the term is within the edit distance of any of the words
+//
+func matchesAnyWord(term string, words []string, distance int) bool {
+	for _, word := range words {
+		if levenshtein(term, word) <= distance {
+			return true
+		}
+	}
+	return false
+}
+
+// isSubsequence checks whether all the characters of term appear in order within s
+//
+func isSubsequence(term string, s string) bool {
+	termRunes := []rune(term)
+	i := 0
+	for _, r := range s {
+		if i < len(termRunes) && termRunes[i] == r {
+			i++
+		}
+	}
+	return i == len(termRunes)
+}
+
+// minimumAbbreviation is the shortest term taken as an abbreviation, and the smallest part of the
+// name (as a ratio of its length) it must be
+//
+const (
+	minimumAbbreviation      = 3
+	minimumAbbreviationRatio = 0.5
+)
+
+// abbreviates checks whether the term is an abbreviation of a name: its characters appear in order within
+// the name, starting with the first, and it is long enough (for the name) not to match by chance
+//
+func abbreviates(term string, name string) bool {
+	termLength, nameLength := len([]rune(term)), len([]rune(name))
+	if termLength < minimumAbbreviation || float64(termLength) < minimumAbbreviationRatio*float64(nameLength) {
+		return false
+	}
+	return strings.HasPrefix(name, string([]rune(term)[:1])) && isSubsequence(term, name)
+}
+
+// levenshtein calculates the edit distance between two strings
+//
+func levenshtein(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = minimum(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
+
+func minimum(values ...int) int {
+	result := values[0]
+	for _, v := range values[1:] {
+		if v < result {
+			result = v
+		}
+	}
+	return result
+}
@@ -0,0 +1,89 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"golang", "golang", 0},
+		{"golang", "gloang", 2},
+		{"nodejs", "node", 2},
+		{"café", "cafe", 1},
+	}
+
+	for _, test := range tests {
+		if got := levenshtein(test.a, test.b); got != test.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestAbbreviates(t *testing.T) {
+	tests := []struct {
+		term, name string
+		want       bool
+	}{
+		{"msvc", "microservice", false},
+		{"mcrsvc", "microservice", true},
+		{"tsms", "tsmicroservice", false},
+		{"prj", "project", false},
+		{"projt", "project", true},
+		{"rjct", "project", false},
+		{"ap", "api", false},
+		{"api", "account-payments-importer", false},
+	}
+
+	for _, test := range tests {
+		if got := abbreviates(test.term, test.name); got != test.want {
+			t.Errorf("abbreviates(%q, %q) = %t, want %t", test.term, test.name, got, test.want)
+		}
+	}
+}
+
+func TestScoreScaffold(t *testing.T) {
+	scaffold := &config.PolarisScaffold{
+		Name: "core/stable/starter/nodejs-microservice",
+		Spec: config.PolarisScaffoldSpec{
+			Description: "A typescript microservice with a Dockerfile",
+			Tags:        []string{"typescript", "http"},
+			Language:    "javascript",
+			Category:    "service",
+		},
+	}
+
+	tests := []struct {
+		name  string
+		terms []string
+		want  int
+	}{
+		{"no terms", nil, 1},
+		{"the base name", []string{"nodejs-microservice"}, 100},
+		{"part of the name", []string{"nodejs"}, 50},
+		{"a typo of a word of the name", []string{"nodjs"}, 25},
+		{"an abbreviation of the name", []string{"nodejsmcrsvc"}, 10},
+		{"a tag", []string{"http"}, 40},
+		{"a typo of a tag", []string{"htp"}, 10},
+		{"the language", []string{"javascript"}, 30},
+		{"the category", []string{"service"}, 50 + 30},
+		{"a word of the description", []string{"dockerfile"}, 15},
+		{"a short scattered term", []string{"nms"}, 0},
+		{"every term must match", []string{"http", "python"}, 0},
+		{"terms add up", []string{"http", "javascript"}, 70},
+	}
+
+	for _, test := range tests {
+		if got := scoreScaffold(scaffold, test.terms); got != test.want {
+			t.Errorf("%s: scoreScaffold(%v) = %d, want %d", test.name, test.terms, got, test.want)
+		}
+	}
+}
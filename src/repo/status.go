@@ -0,0 +1,239 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+	"github.com/synthesis-labs/polaris-cli/src/options"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+)
+
+// RepositoryStatus is the state of a repository's local clone, what it provides and how it compares
+// to the remote
+//
+type RepositoryStatus struct {
+	Repository string
+	State      string
+	Metadata   *SyncMetadata
+	Projects   int
+	Components int
+	Remote     string
+}
+
+// GetRepositoryStatuses returns the status of all repositories. The remote of each repository is
+// checked (several at a time) to see whether it is ahead of the local clone, unless offline
+//
+func GetRepositoryStatuses(polarisHome string, polarisConfig *config.PolarisConfig) ([]RepositoryStatus, error) {
+	projects, err := ListProjects(polarisHome, polarisConfig)
+	if err != nil {
+		return nil, err
+	}
+	components, err := ListComponents(polarisHome, polarisConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	repoNames := []string{}
+	for repoName := range polarisConfig.Repositories {
+		repoNames = append(repoNames, repoName)
+	}
+	sort.Strings(repoNames)
+
+	statuses := make([]RepositoryStatus, len(repoNames))
+	for i, repoName := range repoNames {
+		metadata, err := GetSyncMetadata(polarisHome, repoName)
+		if err != nil {
+			return nil, err
+		}
+		statuses[i] = RepositoryStatus{
+			Repository: repoName,
+			State:      repositoryState(polarisHome, repoName, polarisConfig.Repositories[repoName], metadata),
+			Metadata:   metadata,
+		}
+		for _, project := range projects {
+			if project.Repository == repoName {
+				statuses[i].Projects++
+			}
+		}
+		for _, component := range components {
+			if component.Repository == repoName {
+				statuses[i].Components++
+			}
+		}
+	}
+
+	concurrency := polarisConfig.SyncConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultSyncConcurrency
+	}
+
+	slots := make(chan struct{}, concurrency)
+	var wait sync.WaitGroup
+	for i := range statuses {
+		repoConfig := polarisConfig.Repositories[statuses[i].Repository]
+		if options.IsOffline() {
+			statuses[i].Remote = "not checked (offline)"
+			continue
+		}
+		if repoConfig.IsPinned() {
+			statuses[i].Remote = "pinned"
+			continue
+		}
+		if repoConfig.Local != "" {
+			statuses[i].Remote = "local"
+			continue
+		}
+
+		// Credentials may need prompting for, so are worked out before checking remotes at once
+		//
+		auth, err := repositoryAuth(statuses[i].Repository, repoConfig)
+		if err != nil {
+			statuses[i].Remote = fmt.Sprintf("unknown (%s)", err)
+			continue
+		}
+		wait.Add(1)
+		go func(status *RepositoryStatus, repoConfig config.PolarisRepository, auth transport.AuthMethod) {
+			defer wait.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			status.Remote = remoteState(polarisHome, status.Repository, repoConfig, auth)
+		}(&statuses[i], repoConfig, auth)
+	}
+	wait.Wait()
+
+	return statuses, nil
+}
+
+// repositoryState describes the local clone of a repository: not synced, failed (its last sync),
+// due (to be synced), pinned, synced or local
+//
+func repositoryState(polarisHome string, repoName string, repoConfig config.PolarisRepository, metadata *SyncMetadata) string {
+	if repoConfig.Local != "" {
+		return "local"
+	}
+	if _, err := os.Stat(RepositoryPath(polarisHome, repoName, repoConfig)); os.IsNotExist(err) {
+		return "not synced"
+	}
+	if metadata.Error != "" {
+		return "failed"
+	}
+	if repoConfig.IsPinned() {
+		if metadata.Ref != repoConfig.Ref {
+			return "due"
+		}
+		return "pinned"
+	}
+	interval := syncInterval(repoConfig)
+	if interval >= 0 && time.Since(metadata.LastSync) > interval {
+		return "due"
+	}
+	return "synced"
+}
+
+// remoteState compares the commit the remote's ref points to with the commit checked out locally
+//
+func remoteState(polarisHome string, repoName string, repoConfig config.PolarisRepository, auth transport.AuthMethod) string {
+	repository, err := git.PlainOpen(RepositoryPath(polarisHome, repoName, repoConfig))
+	if err != nil {
+		return "unknown (not cloned)"
+	}
+	remote, err := repository.Remote("origin")
+	if err != nil {
+		return fmt.Sprintf("unknown (%s)", err)
+	}
+	refs, err := remote.List(&git.ListOptions{Auth: auth})
+	if err != nil {
+		return fmt.Sprintf("unknown (%s)", err)
+	}
+
+	local := headCommit(repository)
+	for _, ref := range refs {
+		if ref.Name().String() != repoConfig.Ref {
+			continue
+		}
+		if ref.Hash().String() == local {
+			return "up to date"
+		}
+		return remoteChange(repository, local, ref.Hash().String())
+	}
+	return fmt.Sprintf("unknown (%s not found)", repoConfig.Ref)
+}
+
+// remoteChange describes how the remote's commit relates to the local one: ahead when the remote has moved on
+// from it, behind when the remote points to one of its ancestors, and differs otherwise. A remote commit which
+// hasn't been fetched can't be told apart from a rewritten history, so differs too
+//
+func remoteChange(repository *git.Repository, local string, remote string) string {
+	remoteCommit, err := repository.CommitObject(plumbing.NewHash(remote))
+	if err != nil {
+		return fmt.Sprintf("differs (%s, not fetched)", shortHash(remote))
+	}
+	localCommit, err := repository.CommitObject(plumbing.NewHash(local))
+	if err != nil {
+		return fmt.Sprintf("differs (%s)", shortHash(remote))
+	}
+
+	if isAncestor(localCommit, remoteCommit) {
+		return fmt.Sprintf("ahead (%s)", shortHash(remote))
+	}
+	if isAncestor(remoteCommit, localCommit) {
+		return fmt.Sprintf("behind (%s)", shortHash(remote))
+	}
+	return fmt.Sprintf("differs (%s)", shortHash(remote))
+}
+
+// errFound stops walking the history once the commit looked for is found
+//
+var errFound = errors.New("found")
+
+// isAncestor tells whether ancestor is in the history of commit
+//
+func isAncestor(ancestor *object.Commit, commit *object.Commit) bool {
+	err := object.NewCommitPreorderIter(commit, nil, nil).ForEach(func(c *object.Commit) error {
+		if c.Hash == ancestor.Hash {
+			return errFound
+		}
+		return nil
+	})
+	return err == errFound
+}
+
+// PrintRepositoryStatuses prints a table of the status of each repository
+//
+func PrintRepositoryStatuses(statuses []RepositoryStatus) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, "REPOSITORY\tSTATE\tLAST SYNC\tCOMMIT\tPROJECTS\tCOMPONENTS\tREMOTE\t")
+
+	for _, status := range statuses {
+		lastSync := "never"
+		if !status.Metadata.LastSync.IsZero() {
+			lastSync = status.Metadata.LastSync.Format("2006-01-02 15:04")
+		}
+		commit := shortHash(status.Metadata.Commit)
+		if status.State == "local" {
+			lastSync, commit = "-", "-"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%d\t%d\t%s\t\n", status.Repository, status.State, lastSync,
+			commit, status.Projects, status.Components, status.Remote)
+	}
+	writer.Flush()
+
+	// The errors are too long for the table
+	//
+	for _, status := range statuses {
+		if status.Metadata.Error != "" {
+			fmt.Printf("\n%s last failed to sync at %s: %s\n", status.Repository,
+				status.Metadata.LastAttempt.Format("2006-01-02 15:04"), status.Metadata.Error)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+)
+
+// storeCommit stores a commit with the given parents, returning its hash
+//
+func storeCommit(t *testing.T, storage *memory.Storage, message string, parents ...plumbing.Hash) plumbing.Hash {
+	signature := object.Signature{Name: "Test", Email: "test@example.com", When: time.Unix(0, 0).UTC()}
+	commit := &object.Commit{Author: signature, Committer: signature, Message: message, ParentHashes: parents}
+
+	encoded := storage.NewEncodedObject()
+	if err := commit.Encode(encoded); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := storage.SetEncodedObject(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestRemoteChange(t *testing.T) {
+	storage := memory.NewStorage()
+	repository, err := git.Init(storage, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// first <- second <- third, with other branching off first
+	//
+	first := storeCommit(t, storage, "first")
+	second := storeCommit(t, storage, "second", first)
+	third := storeCommit(t, storage, "third", second)
+	other := storeCommit(t, storage, "other", first)
+	unfetched := plumbing.NewHash("9f8e7d6c5b4a39281706f5e4d3c2b1a098765432")
+
+	tests := []struct {
+		name   string
+		local  plumbing.Hash
+		remote plumbing.Hash
+		want   string
+	}{
+		{"remote moved on", first, third, "ahead (" + shortHash(third.String()) + ")"},
+		{"remote moved back", third, second, "behind (" + shortHash(second.String()) + ")"},
+		{"histories diverged", second, other, "differs (" + shortHash(other.String()) + ")"},
+		{"remote not fetched", third, unfetched, "differs (9f8e7d6, not fetched)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := remoteChange(repository, test.local.String(), test.remote.String()); got != test.want {
+				t.Errorf("remoteChange = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
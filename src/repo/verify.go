@@ -0,0 +1,155 @@
+package repo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// UnverifiedError is returned for repository content which isn't signed by a trusted key
+//
+type UnverifiedError struct {
+	Err error
+}
+
+func (err *UnverifiedError) Error() string {
+	return fmt.Sprintf("%s (use --allow-unverified to accept it)", err.Err)
+}
+
+// trustedKeys reads the armored public keys a repository trusts
+//
+func trustedKeys(repoName string, repoConfig config.PolarisRepository) ([]string, error) {
+	keys := []string{}
+	for _, keyPath := range repoConfig.TrustedKeys {
+		keyPath, err := expandHome(keyPath)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ioutil.ReadFile(keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read the trusted key %s of repository %s: %s", keyPath, repoName, err)
+		}
+		keys = append(keys, string(key))
+	}
+	return keys, nil
+}
+
+// signer names who an entity is, by its primary identity: the one last marked as primary by its
+// self-signature, otherwise the one last signed. The identities are held in a map, so they are looked
+// at in order of name for the same key to always be named the same way
+//
+func signer(entity *openpgp.Entity) string {
+	names := []string{}
+	for name := range entity.Identities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	primary := ""
+	var primarySignature *packet.Signature
+	for _, name := range names {
+		signature := entity.Identities[name].SelfSignature
+		if signature == nil {
+			continue
+		}
+		if primarySignature == nil || isPrimaryID(signature) && !isPrimaryID(primarySignature) ||
+			isPrimaryID(signature) == isPrimaryID(primarySignature) && signature.CreationTime.After(primarySignature.CreationTime) {
+			primary = name
+			primarySignature = signature
+		}
+	}
+	if primary == "" {
+		return fmt.Sprintf("key %X", entity.PrimaryKey.KeyId)
+	}
+	return primary
+}
+
+// isPrimaryID returns whether a self-signature marks its identity as the primary one
+//
+func isPrimaryID(signature *packet.Signature) bool {
+	return signature.IsPrimaryId != nil && *signature.IsPrimaryId
+}
+
+// verifySignature checks a signature against each of the trusted keys in turn, returning who signed it
+//
+func verifySignature(what string, signature string, keys []string, verify func(string) (*openpgp.Entity, error)) (string, error) {
+	if signature == "" {
+		return "", fmt.Errorf("%s is not signed", what)
+	}
+
+	var lastErr error
+	for _, key := range keys {
+		entity, err := verify(key)
+		if err == nil {
+			return signer(entity), nil
+		}
+		lastErr = err
+	}
+	return "", fmt.Errorf("%s is not signed by a trusted key: %s", what, lastErr)
+}
+
+// verifyRevision checks that a commit was signed by one of the trusted keys, or if the revision is
+// an annotated tag which is signed, that the tag was. It returns who signed it
+//
+func verifyRevision(repository *git.Repository, revision plumbing.ReferenceName, commit *object.Commit, keys []string) (string, error) {
+	if revision.IsTag() {
+		if ref, err := repository.Reference(revision, true); err == nil {
+			if tag, err := repository.TagObject(ref.Hash()); err == nil && tag.PGPSignature != "" {
+				return verifySignature(fmt.Sprintf("Tag %s", revision.Short()), tag.PGPSignature, keys, func(key string) (*openpgp.Entity, error) {
+					entity, err := tag.Verify(key)
+					if err == nil || !strings.HasSuffix(tag.Message, "\n") {
+						return entity, err
+					}
+
+					// Tag.Decode in go-git v4.8.1 splits a signed tag's message from its signature by line,
+					// adding a newline after each one including the empty one left after the signature, so
+					// the message ends with an extra newline which has to go for the tag to encode as it was
+					// signed
+					//
+					trimmed := *tag
+					trimmed.Message = strings.TrimSuffix(tag.Message, "\n")
+					if entity, trimmedErr := trimmed.Verify(key); trimmedErr == nil {
+						return entity, nil
+					}
+					return nil, err
+				})
+			}
+		}
+	}
+	return verifySignature(fmt.Sprintf("Commit %s", commit.Hash.String()[:7]), commit.PGPSignature, keys, commit.Verify)
+}
+
+// verifyRepository checks what is checked out in the clone of a repository was signed by one of the
+// keys it trusts, returning who signed it (nothing when the repository trusts no keys)
+//
+func verifyRepository(localPath string, repoName string, repoConfig config.PolarisRepository) (string, error) {
+	if len(repoConfig.TrustedKeys) == 0 {
+		return "", nil
+	}
+	keys, err := trustedKeys(repoName, repoConfig)
+	if err != nil {
+		return "", err
+	}
+
+	repository, err := git.PlainOpen(localPath)
+	if err != nil {
+		return "", err
+	}
+	head, err := repository.Head()
+	if err != nil {
+		return "", err
+	}
+	commit, err := repository.CommitObject(head.Hash())
+	if err != nil {
+		return "", err
+	}
+	return verifyRevision(repository, plumbing.ReferenceName(repoConfig.Ref), commit, keys)
+}
@@ -0,0 +1,120 @@
+package repo
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+)
+
+// readTestdata reads a file from testdata
+//
+func readTestdata(t *testing.T, name string) []byte {
+	contents, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return contents
+}
+
+// signedRepository sets up a repository holding the commit and the annotated tag 1.0.0 of it (made by
+// git and signed by gpg with the key in testdata/signer.asc), with master pointing at the commit
+//
+func signedRepository(t *testing.T) *git.Repository {
+	storage := memory.NewStorage()
+	repository, err := git.Init(storage, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	objects := []struct {
+		name       string
+		objectType plumbing.ObjectType
+		hash       string
+	}{
+		{"signed-commit", plumbing.CommitObject, "04b93944ea56cde0829419caea0989ce902bb971"},
+		{"signed-tag", plumbing.TagObject, "42f7193ca0f850bcaf75dfa40de56f9fac798c06"},
+	}
+	for _, object := range objects {
+		encoded := storage.NewEncodedObject()
+		encoded.SetType(object.objectType)
+		writer, err := encoded.Writer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write(readTestdata(t, object.name)); err != nil {
+			t.Fatal(err)
+		}
+		writer.Close()
+
+		hash, err := storage.SetEncodedObject(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hash.String() != object.hash {
+			t.Fatalf("%s has hash %s, want %s", object.name, hash, object.hash)
+		}
+	}
+
+	references := map[string]string{
+		"refs/heads/master": "04b93944ea56cde0829419caea0989ce902bb971",
+		"refs/tags/1.0.0":   "42f7193ca0f850bcaf75dfa40de56f9fac798c06",
+	}
+	for name, hash := range references {
+		err := storage.SetReference(plumbing.NewHashReference(plumbing.ReferenceName(name), plumbing.NewHash(hash)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repository
+}
+
+func TestVerifyRevision(t *testing.T) {
+	repository := signedRepository(t)
+	commit, err := repository.CommitObject(plumbing.NewHash("04b93944ea56cde0829419caea0989ce902bb971"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signerKey := string(readTestdata(t, "signer.asc"))
+	otherKey := string(readTestdata(t, "other.asc"))
+
+	tests := []struct {
+		name     string
+		revision string
+		keys     []string
+		want     string
+		wantErr  string
+	}{
+		{"signed tag", "refs/tags/1.0.0", []string{signerKey}, "Scaffold Signer <signer@example.com>", ""},
+		{"signed tag among other keys", "refs/tags/1.0.0", []string{otherKey, signerKey}, "Scaffold Signer <signer@example.com>", ""},
+		{"signed commit", "refs/heads/master", []string{signerKey}, "Scaffold Signer <signer@example.com>", ""},
+		{"tag not signed by a trusted key", "refs/tags/1.0.0", []string{otherKey}, "", "Tag 1.0.0 is not signed by a trusted key"},
+		{"commit not signed by a trusted key", "refs/heads/master", []string{otherKey}, "", "Commit 04b9394 is not signed by a trusted key"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The signer's key has two identities, which are held in a map, so look more than once
+			//
+			for i := 0; i < 10; i++ {
+				got, err := verifyRevision(repository, plumbing.ReferenceName(test.revision), commit, test.keys)
+				if test.wantErr != "" {
+					if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+						t.Fatalf("expected an error containing %q, got %v", test.wantErr, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if got != test.want {
+					t.Fatalf("signed by %q, want %q", got, test.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,337 @@
+package repo
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+	"github.com/synthesis-labs/polaris-cli/src/options"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// splitVersion splits a scaffold reference such as core/stable/starter/project@1.4.0 into
+// the scaffold name and the version (empty when there is no version)
+//
+func splitVersion(scaffoldReference string) (string, string) {
+	at := strings.LastIndex(scaffoldReference, "@")
+	if at < 0 {
+		return scaffoldReference, ""
+	}
+	return scaffoldReference[:at], scaffoldReference[at+1:]
+}
+
+// repositoryForScaffold finds the configured repository a scaffold name lives in, returning the
+// repository name and the path of the scaffold relative to the root of that repository
+//
+func repositoryForScaffold(polarisConfig *config.PolarisConfig, scaffoldName string) (string, string, error) {
+	repoName := ""
+	for name := range polarisConfig.Repositories {
+		if strings.HasPrefix(scaffoldName, name+"/") && len(name) > len(repoName) {
+			repoName = name
+		}
+	}
+	if repoName == "" {
+		return "", "", fmt.Errorf("Unable to find repository for %s", scaffoldName)
+	}
+	return repoName, strings.TrimPrefix(scaffoldName, repoName+"/"), nil
+}
+
+// resolveVersion resolves a version (a tag or a commit hash) to a commit within the repository
+//
+func resolveVersion(repository *git.Repository, version string) (*object.Commit, error) {
+	hash, err := repository.ResolveRevision(plumbing.Revision(version))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to resolve version %s: %s", version, err)
+	}
+	return repository.CommitObject(*hash)
+}
+
+// extractTree writes the contents of a git tree to a local directory, refusing symbolic links which
+// lead outside of it
+//
+func extractTree(tree *object.Tree, localPath string) error {
+	return tree.Files().ForEach(func(file *object.File) error {
+		targetPath := filepath.Join(localPath, filepath.FromSlash(file.Name))
+		if rel, err := filepath.Rel(localPath, targetPath); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("%s is outside of %s", file.Name, localPath)
+		}
+		err := os.MkdirAll(filepath.Dir(targetPath), os.ModePerm)
+		if err != nil {
+			return err
+		}
+
+		// A symbolic link is recreated as one, as long as it leads somewhere within the tree
+		//
+		if file.Mode == filemode.Symlink {
+			link, err := file.Contents()
+			if err != nil {
+				return err
+			}
+			resolved := filepath.Join(filepath.Dir(targetPath), filepath.FromSlash(link))
+			if rel, err := filepath.Rel(localPath, resolved); filepath.IsAbs(link) || err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return fmt.Errorf("%s is a symbolic link to %s, which is outside of %s", file.Name, link, localPath)
+			}
+			return os.Symlink(link, targetPath)
+		}
+
+		var perm os.FileMode = 0644
+		if file.Mode == filemode.Executable {
+			perm = 0755
+		}
+
+		reader, err := file.Reader()
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+
+		target, err := os.OpenFile(targetPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(target, reader)
+		if err != nil {
+			target.Close()
+			return err
+		}
+		return target.Close()
+	})
+}
+
+// getScaffoldVersion renders a scaffold from a particular revision of its repository into
+// POLARIS_HOME/versions, leaving the checked out ref of the repository alone
+//
+func getScaffoldVersion(polarisHome string, polarisConfig *config.PolarisConfig, baseName string, scaffoldName string, version string) (*config.PolarisScaffold, error) {
+	repoName, scaffoldPath, err := repositoryForScaffold(polarisConfig, scaffoldName)
+	if err != nil {
+		return nil, err
+	}
+
+	repository, err := git.PlainOpen(RepositoryPath(polarisHome, repoName, polarisConfig.Repositories[repoName]))
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := resolveVersion(repository, version)
+	if err != nil {
+		return nil, err
+	}
+
+	// The version must be signed by a trusted key too, if the repository has any
+	//
+	if repoConfig := polarisConfig.Repositories[repoName]; len(repoConfig.TrustedKeys) > 0 {
+		keys, err := trustedKeys(repoName, repoConfig)
+		if err != nil {
+			return nil, err
+		}
+		_, err = verifyRevision(repository, plumbing.NewTagReferenceName(version), commit, keys)
+		if err != nil && !options.IsAllowUnverified() {
+			return nil, &UnverifiedError{Err: fmt.Errorf("%s at version %s: %s", scaffoldName, version, err)}
+		} else if err != nil {
+			fmt.Println("Warning:", scaffoldName, "at version", version+":", err)
+		}
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	scaffoldTree, err := tree.Tree(scaffoldPath)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to find %s at version %s: %s", scaffoldName, version, err)
+	}
+
+	// Commits are immutable, so anything previously extracted for this commit can be reused
+	//
+	repositoryPath := filepath.Join(polarisHome, "versions", filepath.FromSlash(repoName), commit.Hash.String())
+	localPath := filepath.Join(repositoryPath, filepath.FromSlash(scaffoldPath))
+	if _, err := os.Stat(filepath.Join(localPath, baseName)); os.IsNotExist(err) {
+		if options.IsVerbose() {
+			fmt.Println("Extracting", scaffoldName, "at", version, "(", commit.Hash.String()[:7], ") to", localPath)
+		}
+		os.RemoveAll(localPath)
+		err = extractTree(scaffoldTree, localPath)
+		if err != nil {
+			os.RemoveAll(localPath)
+			return nil, err
+		}
+
+		// The repository level partials at that revision are needed to render it too
+		//
+		if partialsTree, err := tree.Tree("polaris-partials"); err == nil {
+			partialsPath := filepath.Join(repositoryPath, "polaris-partials")
+			os.RemoveAll(partialsPath)
+			err = extractTree(partialsTree, partialsPath)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	scaffoldData, err := ioutil.ReadFile(filepath.Join(localPath, baseName))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s at version %s is not a %s", scaffoldName, version, baseName)
+	} else if err != nil {
+		return nil, err
+	}
+
+	scaffold := config.PolarisScaffold{}
+	err = yaml.Unmarshal(scaffoldData, &scaffold.Spec)
+	if err != nil {
+		return nil, err
+	}
+	scaffold.Name = fmt.Sprintf("%s@%s", scaffoldName, version)
+	scaffold.LocalPath = localPath
+	scaffold.Repository = repoName
+	scaffold.RepositoryPath = repositoryPath
+	scaffold.Commit = commit.Hash.String()
+	scaffold.Limits = polarisConfig.Repositories[repoName].Limits.WithDefaults()
+
+	return &scaffold, nil
+}
+
+// listScaffoldVersions lists the tags of the scaffold's repository in which the scaffold exists
+//
+func listScaffoldVersions(polarisHome string, polarisConfig *config.PolarisConfig, baseName string, scaffoldName string) ([]string, error) {
+	scaffoldName, err := resolveScaffoldName(polarisHome, polarisConfig, baseName, scaffoldName)
+	if err != nil {
+		return nil, err
+	}
+
+	repoName, scaffoldPath, err := repositoryForScaffold(polarisConfig, scaffoldName)
+	if err != nil {
+		return nil, err
+	}
+
+	repository, err := git.PlainOpen(RepositoryPath(polarisHome, repoName, polarisConfig.Repositories[repoName]))
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := repository.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []string{}
+	err = tags.ForEach(func(ref *plumbing.Reference) error {
+		version := ref.Name().Short()
+		commit, err := resolveVersion(repository, version)
+		if err != nil {
+			return nil
+		}
+		if _, err := commit.File(fmt.Sprintf("%s/%s", scaffoldPath, baseName)); err == nil {
+			versions = append(versions, version)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortVersions(versions)
+
+	return versions, nil
+}
+
+// sortVersions sorts tags oldest first by their version numbers, with a pre-release before its release.
+// Tags which aren't versions follow in string order
+//
+func sortVersions(versions []string) {
+	numbers := map[string][]int{}
+	for _, version := range versions {
+		if parsed, err := parseVersion(version); err == nil {
+			numbers[version] = parsed
+		}
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, aIsVersion := numbers[versions[i]]
+		b, bIsVersion := numbers[versions[j]]
+		if aIsVersion != bIsVersion {
+			return aIsVersion
+		}
+		if aIsVersion {
+			if compared := compareVersionNumbers(a, b); compared != 0 {
+				return compared < 0
+			}
+			aIsPreRelease, bIsPreRelease := isPreRelease(versions[i]), isPreRelease(versions[j])
+			if aIsPreRelease != bIsPreRelease {
+				return aIsPreRelease
+			}
+		}
+		return versions[i] < versions[j]
+	})
+}
+
+// isPreRelease tells whether a version has a -pre-release suffix (before any +build suffix)
+//
+func isPreRelease(version string) bool {
+	end := strings.IndexAny(version, "-+")
+	return end >= 0 && version[end] == '-'
+}
+
+// ListProjectVersions returns the versions (tags) available for a project
+//
+func ListProjectVersions(polarisHome string, polarisConfig *config.PolarisConfig, projectName string) ([]string, error) {
+	return listScaffoldVersions(polarisHome, polarisConfig, "polaris-project.yaml", projectName)
+}
+
+// ListComponentVersions returns the versions (tags) available for a component
+//
+func ListComponentVersions(polarisHome string, polarisConfig *config.PolarisConfig, componentName string) ([]string, error) {
+	return listScaffoldVersions(polarisHome, polarisConfig, "polaris-component.yaml", componentName)
+}
+
+// ListFeatureVersions returns the versions (tags) available for a feature pack
+//
+func ListFeatureVersions(polarisHome string, polarisConfig *config.PolarisConfig, featureName string) ([]string, error) {
+	return listScaffoldVersions(polarisHome, polarisConfig, "polaris-feature.yaml", featureName)
+}
+
+// getScaffoldAt gets a scaffold as it was at the commit a project recorded generating it from, so that
+// the project can be rendered again from the same files. Without a commit (the scaffold is in a local
+// repository, or the project was generated before commits were recorded) it is the scaffold as it is now
+//
+func getScaffoldAt(polarisHome string, polarisConfig *config.PolarisConfig, baseName string, scaffoldName string, commit string, get func(string, *config.PolarisConfig, string) (*config.PolarisScaffold, error)) (*config.PolarisScaffold, error) {
+	if commit == "" {
+		scaffold, err := get(polarisHome, polarisConfig, scaffoldName)
+		if err == nil && scaffold.Commit != "" {
+			fmt.Println("Warning: polaris-project.yaml doesn't record the commit", scaffoldName, "was generated from, so it is taken to be", scaffold.Commit[:7])
+		}
+		return scaffold, err
+	}
+
+	scaffoldName, err := resolveScaffoldName(polarisHome, polarisConfig, baseName, scaffoldName)
+	if err != nil {
+		return nil, err
+	}
+	baseScaffoldName, _ := splitVersion(scaffoldName)
+	return getScaffoldVersion(polarisHome, polarisConfig, baseName, baseScaffoldName, commit)
+}
+
+// GetProjectAt returns a project as it was at a commit of its repository
+//
+func GetProjectAt(polarisHome string, polarisConfig *config.PolarisConfig, projectName string, commit string) (*config.PolarisScaffold, error) {
+	return getScaffoldAt(polarisHome, polarisConfig, "polaris-project.yaml", projectName, commit, GetProject)
+}
+
+// GetComponentAt returns a component as it was at a commit of its repository
+//
+func GetComponentAt(polarisHome string, polarisConfig *config.PolarisConfig, componentName string, commit string) (*config.PolarisScaffold, error) {
+	return getScaffoldAt(polarisHome, polarisConfig, "polaris-component.yaml", componentName, commit, GetComponent)
+}
+
+// GetFeatureAt returns a feature pack as it was at a commit of its repository
+//
+func GetFeatureAt(polarisHome string, polarisConfig *config.PolarisConfig, featureName string, commit string) (*config.PolarisScaffold, error) {
+	return getScaffoldAt(polarisHome, polarisConfig, "polaris-feature.yaml", featureName, commit, GetFeature)
+}
@@ -0,0 +1,50 @@
+package repo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSortVersions(t *testing.T) {
+	tests := []struct {
+		name     string
+		versions []string
+		want     []string
+	}{
+		{
+			"numbers rather than strings",
+			[]string{"1.10.0", "1.9.0", "1.2.0", "10.0.0", "2.0.0"},
+			[]string{"1.2.0", "1.9.0", "1.10.0", "2.0.0", "10.0.0"},
+		},
+		{
+			"with a v prefix",
+			[]string{"v1.10.0", "v1.9.1", "1.9.0"},
+			[]string{"1.9.0", "v1.9.1", "v1.10.0"},
+		},
+		{
+			"pre-releases before their release",
+			[]string{"1.0.0", "1.0.0-rc.2", "0.9.0", "1.0.0-rc.1", "1.0.0+build.1"},
+			[]string{"0.9.0", "1.0.0-rc.1", "1.0.0-rc.2", "1.0.0", "1.0.0+build.1"},
+		},
+		{
+			"tags which aren't versions last",
+			[]string{"stable", "1.10.0", "latest", "1.2.0", "release-2019"},
+			[]string{"1.2.0", "1.10.0", "latest", "release-2019", "stable"},
+		},
+		{
+			"none",
+			[]string{},
+			[]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			versions := append([]string{}, test.versions...)
+			sortVersions(versions)
+			if !reflect.DeepEqual(versions, test.want) {
+				t.Errorf("sortVersions(%v) = %v, want %v", test.versions, versions, test.want)
+			}
+		})
+	}
+}
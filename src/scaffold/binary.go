@@ -0,0 +1,102 @@
+package scaffold
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// sniffLength is how much of a file is looked at to decide whether it is binary
+//
+const sniffLength = 8000
+
+// isBinary decides whether the start of a file is binary rather than text: binary if it holds
+// a NUL byte. Text in encodings other than UTF-8 (such as Latin-1) is still text
+//
+func isBinary(sample []byte) bool {
+	return bytes.IndexByte(sample, 0) >= 0
+}
+
+// isBinaryFile sniffs the start of a file to decide whether it is binary. Like copyFile, it follows a
+// symbolic link, so the source must have been through checkSourcePath
+//
+func isBinaryFile(filePath string) (bool, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	sample := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, sample)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return isBinary(sample[:n]), nil
+}
+
+// copyFile streams a file to the target, without reading it into memory. The source must have been
+// through checkSourcePath
+//
+func copyFile(sourcePath string, targetPath string) error {
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	target, err := os.OpenFile(targetPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(target, source)
+	if err != nil {
+		target.Close()
+		return err
+	}
+	return target.Close()
+}
+
+// sameFileContents compares two files a block at a time
+//
+func sameFileContents(pathA string, pathB string) (bool, error) {
+	fileA, err := os.Open(pathA)
+	if err != nil {
+		return false, err
+	}
+	defer fileA.Close()
+	fileB, err := os.Open(pathB)
+	if err != nil {
+		return false, err
+	}
+	defer fileB.Close()
+
+	blockA := make([]byte, 32*1024)
+	blockB := make([]byte, 32*1024)
+	for {
+		nA, errA := io.ReadFull(fileA, blockA)
+		nB, errB := io.ReadFull(fileB, blockB)
+		if !bytes.Equal(blockA[:nA], blockB[:nB]) {
+			return false, nil
+		}
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == io.EOF || errB == io.ErrUnexpectedEOF, nil
+		}
+		if errA != nil {
+			return false, errA
+		}
+		if errB != nil && errB != io.EOF && errB != io.ErrUnexpectedEOF {
+			return false, errB
+		}
+	}
+}
+
+// writeRenderedFile writes a rendered file to its target, streaming a binary file from the scaffold
+//
+func writeRenderedFile(rendered renderedFile) error {
+	if rendered.Binary {
+		return copyFile(rendered.SourcePath, rendered.TargetPath)
+	}
+	return ioutil.WriteFile(rendered.TargetPath, rendered.Contents, 0644)
+}
@@ -0,0 +1,27 @@
+package scaffold
+
+import (
+	"testing"
+)
+
+func TestIsBinary(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample []byte
+		want   bool
+	}{
+		{"empty", []byte{}, false},
+		{"ascii", []byte("name: [[ .Project ]]\n"), false},
+		{"utf-8", []byte("caf\xc3\xa9\n"), false},
+		{"latin-1", []byte("caf\xe9\n"), false},
+		{"utf-8 cut part way through a character", []byte("caf\xc3"), false},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), true},
+		{"utf-16", []byte("n\x00a\x00m\x00e\x00"), true},
+	}
+
+	for _, test := range tests {
+		if got := isBinary(test.sample); got != test.want {
+			t.Errorf("%s: got %t, want %t", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package scaffold
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+)
+
+// previewScaffold renders the scaffold without writing anything, returning the target paths
+//
+func previewScaffold(scaffold *config.PolarisScaffold, scaffoldValues interface{}, localPath string) ([]string, error) {
+	paths := []string{}
+	err := renderScaffold(scaffold, scaffoldValues, localPath, func(rendered renderedFile) error {
+		paths = append(paths, rendered.TargetPath)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// PreviewProject returns the paths a project scaffold would be unpacked to with the given parameters
+//
+func PreviewProject(scaffold *config.PolarisScaffold, parameters map[string]string, localPath string) ([]string, error) {
+	project, _, err := newProjectValues(scaffold, parameters, localPath)
+	if err != nil {
+		return nil, err
+	}
+	return previewScaffold(scaffold, project, localPath)
+}
+
+// PreviewComponent returns the paths a component scaffold would be unpacked to (within the project)
+// with the given parameters
+//
+func PreviewComponent(polarisHome string, componentScaffold *config.PolarisScaffold, project *config.PolarisProject, parameters map[string]string, componentName string, localPath string) ([]string, error) {
+	component, _, err := newComponentValues(polarisHome, componentScaffold, project, parameters, componentName, localPath)
+	if err != nil {
+		return nil, err
+	}
+	return previewScaffold(componentScaffold, component, ".")
+}
+
+// treeNode is a directory (or file when it has no children) within a printed tree
+//
+type treeNode struct {
+	children map[string]*treeNode
+}
+
+// PrintTree prints the paths as a tree
+//
+func PrintTree(paths []string) {
+	root := &treeNode{children: map[string]*treeNode{}}
+	for _, p := range paths {
+		p = filepath.ToSlash(filepath.Clean(p))
+		if p == "." {
+			continue
+		}
+
+		node := root
+		for _, part := range strings.Split(p, "/") {
+			child, found := node.children[part]
+			if !found {
+				child = &treeNode{children: map[string]*treeNode{}}
+				node.children[part] = child
+			}
+			node = child
+		}
+	}
+
+	printTreeNode(root, "")
+}
+
+func printTreeNode(node *treeNode, indent string) {
+	names := []string{}
+	for name := range node.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		connector, childIndent := "├── ", "│   "
+		if i == len(names)-1 {
+			connector, childIndent = "└── ", "    "
+		}
+		fmt.Println(indent + connector + name)
+		printTreeNode(node.children[name], indent+childIndent)
+	}
+}
+
+var (
+	markdownHeading  = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	markdownList     = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	markdownLink     = regexp.MustCompile(`\[([^\]]*)\]\(([^)]*)\)`)
+	markdownImage    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	markdownEmphasis = regexp.MustCompile(`(\*\*|__|\*|_|` + "`" + `)([^*_` + "`" + `]+)(\*\*|__|\*|_|` + "`" + `)`)
+)
+
+// RenderReadme renders the README.md of a scaffold as plain text for the terminal, returning an
+// empty string if the scaffold has no README
+//
+func RenderReadme(scaffold *config.PolarisScaffold) (string, error) {
+	var readme []byte
+	for _, name := range []string{"README.md", "readme.md", "README"} {
+		data, err := ioutil.ReadFile(filepath.Join(scaffold.LocalPath, name))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		readme = data
+		break
+	}
+	if readme == nil {
+		return "", nil
+	}
+
+	var rendered []string
+	inCode := false
+	for _, line := range strings.Split(strings.Replace(string(readme), "\r\n", "\n", -1), "\n") {
+		// Code blocks are indented and otherwise left alone
+		//
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			rendered = append(rendered, "    "+line)
+			continue
+		}
+
+		line = markdownImage.ReplaceAllString(line, "[$1]")
+		line = markdownLink.ReplaceAllString(line, "$1 ($2)")
+		line = markdownEmphasis.ReplaceAllString(line, "$2")
+
+		if match := markdownHeading.FindStringSubmatch(line); match != nil {
+			underline := "-"
+			if len(match[1]) == 1 {
+				underline = "="
+			}
+			rendered = append(rendered, match[2], strings.Repeat(underline, len(match[2])))
+			continue
+		}
+		if match := markdownList.FindStringSubmatch(line); match != nil {
+			rendered = append(rendered, match[1]+"  • "+match[2])
+			continue
+		}
+
+		rendered = append(rendered, line)
+	}
+
+	return strings.TrimSpace(strings.Join(rendered, "\n")), nil
+}
@@ -0,0 +1,142 @@
+package scaffold
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// diffContext is how many unchanged lines are shown around each change
+//
+const diffContext = 3
+
+// diffLine is a line of a diff: ' ' for a line in both, '-' for a line only before and '+' for one only after
+//
+type diffLine struct {
+	Op   byte
+	Line []byte
+}
+
+// diffLines lines up the lines before and after, in order
+//
+func diffLines(before [][]byte, after [][]byte) []diffLine {
+	matches := matchLines(before, after)
+	lines := []diffLine{}
+	i, j := 0, 0
+	for i < len(before) || j < len(after) {
+		switch {
+		case i < len(before) && matches[i] < 0:
+			lines = append(lines, diffLine{'-', before[i]})
+			i++
+		case j < len(after) && (i == len(before) || matches[i] != j):
+			lines = append(lines, diffLine{'+', after[j]})
+			j++
+		default:
+			lines = append(lines, diffLine{' ', before[i]})
+			i++
+			j++
+		}
+	}
+	return lines
+}
+
+// writeDiff writes the differences between two versions of a file as unified diff hunks
+//
+func writeDiff(writer io.Writer, before []byte, after []byte) {
+	lines := diffLines(splitLines(before), splitLines(after))
+
+	for start := 0; start < len(lines); {
+		// Find the next change, and the end of the hunk around it (changes closer together than
+		// the context either side of them share a hunk)
+		//
+		first := start
+		for first < len(lines) && lines[first].Op == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			return
+		}
+		last := first
+		for next := first; next < len(lines) && next <= last+2*diffContext; next++ {
+			if lines[next].Op != ' ' {
+				last = next
+			}
+		}
+
+		from := first - diffContext
+		if from < start {
+			from = start
+		}
+		to := last + diffContext + 1
+		if to > len(lines) {
+			to = len(lines)
+		}
+
+		// Line numbers count from 1, and the lines before the hunk count towards both
+		//
+		beforeLine, afterLine := 1, 1
+		for _, line := range lines[:from] {
+			if line.Op != '+' {
+				beforeLine++
+			}
+			if line.Op != '-' {
+				afterLine++
+			}
+		}
+		beforeCount, afterCount := 0, 0
+		for _, line := range lines[from:to] {
+			if line.Op != '+' {
+				beforeCount++
+			}
+			if line.Op != '-' {
+				afterCount++
+			}
+		}
+
+		// An empty side is numbered by the line it follows
+		//
+		if beforeCount == 0 {
+			beforeLine--
+		}
+		if afterCount == 0 {
+			afterLine--
+		}
+		fmt.Fprintf(writer, "@@ -%d,%d +%d,%d @@\n", beforeLine, beforeCount, afterLine, afterCount)
+		for _, line := range lines[from:to] {
+			fmt.Fprintf(writer, "%c%s", line.Op, line.Line)
+			if !bytes.HasSuffix(line.Line, []byte("\n")) {
+				fmt.Fprint(writer, "\n\\ No newline at end of file\n")
+			}
+		}
+		start = to
+	}
+}
+
+// WriteChanges writes the changes made to the files of the project (or which would be, for a dry run) as a diff
+//
+func WriteChanges(writer io.Writer, changes []FileChange) {
+	for _, change := range changes {
+		oldPath, newPath := "/dev/null", "/dev/null"
+		if change.OldPath != "" {
+			oldPath = "a/" + filepath.ToSlash(filepath.Clean(change.OldPath))
+		}
+		if change.NewPath != "" {
+			newPath = "b/" + filepath.ToSlash(filepath.Clean(change.NewPath))
+		}
+
+		// A binary file is only ever moved, created or removed
+		//
+		moved := change.OldPath != "" && change.NewPath != "" && filepath.Clean(change.OldPath) != filepath.Clean(change.NewPath)
+		if moved && (change.Binary || bytes.Equal(change.Before, change.After)) {
+			fmt.Fprintf(writer, "rename from %s\nrename to %s\n", oldPath[2:], newPath[2:])
+			continue
+		}
+		if change.Binary {
+			fmt.Fprintf(writer, "Binary files %s and %s differ\n", oldPath, newPath)
+			continue
+		}
+		fmt.Fprintf(writer, "--- %s\n+++ %s\n", oldPath, newPath)
+		writeDiff(writer, change.Before, change.After)
+	}
+}
@@ -0,0 +1,56 @@
+package scaffold
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   string
+	}{
+		{
+			name:   "unchanged",
+			before: "a\nb\n",
+			after:  "a\nb\n",
+			want:   "",
+		},
+		{
+			name:   "a changed line with context",
+			before: "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			after:  "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			want:   "@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name:   "changes far apart make separate hunks",
+			before: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			after:  "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			want:   "@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+ten\n",
+		},
+		{
+			name:   "a created file",
+			before: "",
+			after:  "a\n",
+			want:   "@@ -0,0 +1,1 @@\n+a\n",
+		},
+		{
+			name:   "a missing line ending",
+			before: "a\nb",
+			after:  "a\nc",
+			want:   "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buff bytes.Buffer
+			writeDiff(&buff, []byte(test.before), []byte(test.after))
+			if buff.String() != test.want {
+				t.Errorf("got\n%q\nwant\n%q", buff.String(), test.want)
+			}
+		})
+	}
+}
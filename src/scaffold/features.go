@@ -0,0 +1,164 @@
+package scaffold
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+	"github.com/synthesis-labs/polaris-cli/src/options"
+	"github.com/synthesis-labs/polaris-cli/src/secrets"
+)
+
+// featureName returns the name of a feature pack without its version
+//
+func featureName(featureScaffoldName string) string {
+	if at := strings.LastIndex(featureScaffoldName, "@"); at >= 0 {
+		return featureScaffoldName[:at]
+	}
+	return featureScaffoldName
+}
+
+// FeatureKey is what the scaffolds of the feature packs recorded in a project are keyed by, as the same
+// feature pack may have been added to different components at different commits
+//
+func FeatureKey(feature config.PolarisProjectFeature) string {
+	return feature.Scaffold + " " + feature.Commit
+}
+
+// withFeatureParameters returns a copy of the (resolved) component values with the feature pack's
+// parameters added to the component's, which is what a feature pack is rendered with
+//
+func withFeatureParameters(component *config.PolarisComponent, featureParameters map[string]string) *config.PolarisComponent {
+	parameters := map[string]string{}
+	for name, value := range component.Parameters {
+		parameters[name] = value
+	}
+	for name, value := range featureParameters {
+		parameters[name] = value
+	}
+
+	withFeature := *component
+	withFeature.Parameters = parameters
+	return &withFeature
+}
+
+// resolveFeatureValues returns the values a recorded feature pack is rendered with, for the (resolved) component values
+//
+func resolveFeatureValues(polarisHome string, component *config.PolarisComponent, feature config.PolarisProjectFeature) (*config.PolarisComponent, error) {
+	parameters, err := secrets.ResolveAll(polarisHome, feature.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	return withFeatureParameters(component, parameters), nil
+}
+
+// rerenderComponent renders a component, along with the feature packs added to it, again with new values. The
+// old and new scaffolds differ when the component is being upgraded, with the feature packs keyed by FeatureKey
+//
+func rerenderComponent(polarisHome string, oldScaffold *config.PolarisScaffold, newScaffold *config.PolarisScaffold, oldFeatureScaffolds map[string]*config.PolarisScaffold, newFeatureScaffolds map[string]*config.PolarisScaffold, recorded config.PolarisProjectComponent, oldComponent *config.PolarisComponent, newComponent *config.PolarisComponent, report *RerenderReport) error {
+	err := rerender(oldScaffold, newScaffold, oldComponent, newComponent, ".", report)
+	if err != nil {
+		return err
+	}
+
+	for _, feature := range recorded.Features {
+		oldFeatureScaffold, foundOld := oldFeatureScaffolds[FeatureKey(feature)]
+		newFeatureScaffold, foundNew := newFeatureScaffolds[FeatureKey(feature)]
+		if !foundOld || !foundNew {
+			return fmt.Errorf("Feature %s of component %s has not been loaded", feature.Scaffold, oldComponent.Component)
+		}
+
+		oldFeature, err := resolveFeatureValues(polarisHome, oldComponent, feature)
+		if err != nil {
+			return err
+		}
+		newFeature, err := resolveFeatureValues(polarisHome, newComponent, feature)
+		if err != nil {
+			return err
+		}
+		err = rerender(oldFeatureScaffold, newFeatureScaffold, oldFeature, newFeature, ".", report)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddFeature renders a feature pack into an existing component of the project within the current
+// directory, using the component's recorded parameters along with the feature pack's own
+//
+func AddFeature(polarisHome string, featureScaffold *config.PolarisScaffold, project *config.PolarisProject, componentName string, parameters map[string]string, overwrite bool) error {
+	component, err := recordedComponentValues(project, componentName)
+	if err != nil {
+		return err
+	}
+
+	recorded := project.Components[componentName]
+	for _, feature := range recorded.Features {
+		if featureName(feature.Scaffold) == featureName(featureScaffold.Name) {
+			return fmt.Errorf("Feature %s has already been added to component %s", featureName(featureScaffold.Name), componentName)
+		}
+	}
+
+	// Resolve the feature pack's own parameters, falling back to its sources and defaults (which see the
+	// values of the project's secrets rather than their references)
+	//
+	values, err := componentDefaultValues(polarisHome, project, componentName)
+	if err != nil {
+		return err
+	}
+	featureParameters, specParameters, err := resolveParameters(featureScaffold.Spec.Parameters, parameters, values, newRenderBudget(featureScaffold))
+	if err != nil {
+		return err
+	}
+	if options.IsVerbose() {
+		for paramKey, paramValue := range featureParameters {
+			fmt.Println("Feature parameter", paramKey, displayValue(specParameters, paramKey, paramValue))
+		}
+	}
+
+	resolvedComponent, err := resolveComponentValues(polarisHome, component)
+	if err != nil {
+		return err
+	}
+	feature := config.PolarisProjectFeature{
+		Scaffold:   featureScaffold.Name,
+		Commit:     featureScaffold.Commit,
+		Parameters: featureParameters,
+	}
+	resolved, err := resolveFeatureValues(polarisHome, resolvedComponent, feature)
+	if err != nil {
+		return err
+	}
+
+	renderedFiles, err := renderUnpack(featureScaffold, resolved, ".", overwrite)
+	if err != nil {
+		return err
+	}
+	patched, err := planPatches(featureScaffold, resolved, ".", renderedFiles)
+	if err != nil {
+		return err
+	}
+	err = writeUnpacked(renderedFiles, patched)
+	if err != nil {
+		return err
+	}
+
+	// As for a component, secrets are only stored once the files are written
+	//
+	keyPrefix, err := secretKeyPrefix(project)
+	if err != nil {
+		return err
+	}
+	err = storeSecrets(polarisHome, specParameters, feature.Parameters, fmt.Sprintf("%s/%s/%s", keyPrefix, componentName, featureName(featureScaffold.Name)), false)
+	if err != nil {
+		return err
+	}
+
+	// Record the feature pack with the component so that it is rendered again along with it
+	//
+	recorded.Features = append(recorded.Features, feature)
+	project.Components[componentName] = recorded
+	return SaveLocalProject(".", project)
+}
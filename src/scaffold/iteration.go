@@ -0,0 +1,84 @@
+package scaffold
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+)
+
+// findIteration finds the iteration (if any) a source file falls under, being one marking either
+// the file itself or one of the directories it is in
+//
+func findIteration(scaffold *config.PolarisScaffold, sourcePath string) (*config.PolarisScaffoldIteration, error) {
+	relativePath, err := filepath.Rel(scaffold.LocalPath, sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	relativePath = filepath.ToSlash(relativePath)
+
+	var found *config.PolarisScaffoldIteration
+	for i, iteration := range scaffold.Spec.Iterate {
+		iterationPath := strings.Trim(filepath.ToSlash(filepath.Clean(iteration.Path)), "/")
+		if relativePath != iterationPath && !strings.HasPrefix(relativePath, iterationPath+"/") {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("%s is within more than one iteration (%s and %s)", relativePath, found.Path, iteration.Path)
+		}
+		found = &scaffold.Spec.Iterate[i]
+	}
+
+	return found, nil
+}
+
+// iterationItems returns the distinct elements of the list parameter being iterated over, looking
+// in the component parameters before the project parameters
+//
+func iterationItems(iteration *config.PolarisScaffoldIteration, scaffoldValues interface{}) []string {
+	var list string
+	switch values := scaffoldValues.(type) {
+	case *config.PolarisProject:
+		list = values.Parameters[iteration.Over]
+	case *config.PolarisComponent:
+		found := false
+		list, found = values.Parameters[iteration.Over]
+		if !found {
+			list = values.ProjectParameters[iteration.Over]
+		}
+	}
+
+	separator := iteration.Separator
+	if separator == "" {
+		separator = ";"
+	}
+
+	items := []string{}
+	seen := map[string]bool{}
+	for _, item := range strings.Split(list, separator) {
+		item = strings.TrimSpace(item)
+		if item != "" && !seen[item] {
+			items = append(items, item)
+			seen[item] = true
+		}
+	}
+	return items
+}
+
+// withIteration returns the fields of the scaffold values along with the element being rendered
+// (.Item) and its position in the list (.Index)
+//
+func withIteration(scaffoldValues interface{}, item string, index int) map[string]interface{} {
+	result := map[string]interface{}{}
+
+	values := reflect.Indirect(reflect.ValueOf(scaffoldValues))
+	for i := 0; i < values.NumField(); i++ {
+		result[values.Type().Field(i).Name] = values.Field(i).Interface()
+	}
+
+	result["Item"] = item
+	result["Index"] = index
+	return result
+}
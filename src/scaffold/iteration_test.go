@@ -0,0 +1,127 @@
+package scaffold
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+)
+
+func TestWithIteration(t *testing.T) {
+	project := &config.PolarisProject{
+		Project:    "orders",
+		Parameters: map[string]string{"schedules": "0 * * * *"},
+		Scaffold:   "core/stable/starter/project",
+	}
+	values := withIteration(project, "0 * * * *", 2)
+
+	if values["Project"] != "orders" || values["Scaffold"] != "core/stable/starter/project" {
+		t.Errorf("the project's fields are missing: %v", values)
+	}
+	if !reflect.DeepEqual(values["Parameters"], project.Parameters) {
+		t.Errorf("Parameters = %v, want %v", values["Parameters"], project.Parameters)
+	}
+	if values["Item"] != "0 * * * *" || values["Index"] != 2 {
+		t.Errorf("Item, Index = %v, %v, want 0 * * * *, 2", values["Item"], values["Index"])
+	}
+
+	component := config.PolarisComponent{Project: "orders", Component: "api"}
+	values = withIteration(component, "a", 0)
+	if values["Component"] != "api" || values["Item"] != "a" || values["Index"] != 0 {
+		t.Errorf("the component's fields are missing: %v", values)
+	}
+}
+
+func TestIterationItems(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    interface{}
+		separator string
+		want      []string
+	}{
+		{"project", &config.PolarisProject{Parameters: map[string]string{"list": "a;b;c"}}, "", []string{"a", "b", "c"}},
+		{"separator", &config.PolarisProject{Parameters: map[string]string{"list": "a, b,c"}}, ",", []string{"a", "b", "c"}},
+		{"empty entries", &config.PolarisProject{Parameters: map[string]string{"list": ";a;; ;b;"}}, "", []string{"a", "b"}},
+		{"repeated entries", &config.PolarisProject{Parameters: map[string]string{"list": "a;b;a"}}, "", []string{"a", "b"}},
+		{"missing", &config.PolarisProject{Parameters: map[string]string{}}, "", []string{}},
+		{"component", &config.PolarisComponent{
+			Parameters:        map[string]string{"list": "a"},
+			ProjectParameters: map[string]string{"list": "b"},
+		}, "", []string{"a"}},
+		{"component from project", &config.PolarisComponent{
+			Parameters:        map[string]string{},
+			ProjectParameters: map[string]string{"list": "b"},
+		}, "", []string{"b"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			iteration := &config.PolarisScaffoldIteration{Path: "files", Over: "list", Separator: test.separator}
+			if got := iterationItems(iteration, test.values); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("iterationItems = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderPlanKeysIterationsByItem(t *testing.T) {
+	directory, err := ioutil.TempDir("", "polaris-iteration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+
+	scaffoldPath := filepath.Join(directory, "scaffold")
+	err = os.MkdirAll(filepath.Join(scaffoldPath, "cron"), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(scaffoldPath, "cron", "job-[[ .Index ]].yaml"), []byte("[[ .Item ]]\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scaffold := &config.PolarisScaffold{
+		Name:      "test/iteration",
+		LocalPath: scaffoldPath,
+		Spec: config.PolarisScaffoldSpec{
+			Iterate: []config.PolarisScaffoldIteration{{Path: "cron/job-[[ .Index ]].yaml", Over: "schedules"}},
+		},
+	}
+
+	render := func(schedules string) map[string]renderedFile {
+		plan, err := renderPlan(scaffold, &config.PolarisProject{Parameters: map[string]string{"schedules": schedules}}, "project")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return plan
+	}
+	before := render("hourly;daily;weekly")
+	after := render("hourly;weekly")
+
+	// Removing an element removes its file, while the files of the others keep their keys (moving
+	// when their path depends on the position)
+	//
+	key := func(item string) string { return "cron/job-[[ .Index ]].yaml#" + item }
+	if _, found := after[key("daily")]; found {
+		t.Errorf("daily is still rendered: %v", after)
+	}
+	tests := []struct {
+		item   string
+		before string
+		after  string
+	}{
+		{"hourly", "project/cron/job-0.yaml", "project/cron/job-0.yaml"},
+		{"weekly", "project/cron/job-2.yaml", "project/cron/job-1.yaml"},
+	}
+	for _, test := range tests {
+		if got := before[key(test.item)]; got.TargetPath != test.before || string(got.Contents) != test.item+"\n" {
+			t.Errorf("before: %s rendered to %s (%q), want %s", test.item, got.TargetPath, got.Contents, test.before)
+		}
+		if got := after[key(test.item)]; got.TargetPath != test.after || string(got.Contents) != test.item+"\n" {
+			t.Errorf("after: %s rendered to %s (%q), want %s", test.item, got.TargetPath, got.Contents, test.after)
+		}
+	}
+}
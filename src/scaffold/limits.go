@@ -0,0 +1,115 @@
+package scaffold
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+)
+
+// renderBudget keeps track of what rendering a scaffold has produced so far, against the limits
+// of the repository it comes from
+//
+type renderBudget struct {
+	limits     config.PolarisRenderLimits
+	repository string
+	files      int
+	totalSize  int64
+}
+
+// newRenderBudget starts the budget for rendering a scaffold
+//
+func newRenderBudget(scaffold *config.PolarisScaffold) *renderBudget {
+	repository := scaffold.Repository
+	if repository == "" {
+		repository = scaffold.Name
+	}
+	return &renderBudget{limits: scaffold.Limits.WithDefaults(), repository: repository}
+}
+
+// limitError reports which limit was hit
+//
+func (budget *renderBudget) limitError(what string, limit string, value interface{}) error {
+	return fmt.Errorf("Rendering %s hit the %s limit (%v) of repository %s", what, limit, value, budget.repository)
+}
+
+// limitedBuffer is a buffer which refuses to grow beyond the size of a file or what is left of the total.
+// Once cancelled (when the template writing to it is abandoned) it refuses to be written to at all, so
+// that the abandoned template stops and leaves the budget alone
+//
+type limitedBuffer struct {
+	bytes.Buffer
+	budget    *renderBudget
+	what      string
+	mutex     sync.Mutex
+	cancelled bool
+}
+
+func (buff *limitedBuffer) Write(p []byte) (int, error) {
+	buff.mutex.Lock()
+	defer buff.mutex.Unlock()
+	if buff.cancelled {
+		return 0, fmt.Errorf("Rendering %s was abandoned", buff.what)
+	}
+
+	size := int64(buff.Len() + len(p))
+	if size > buff.budget.limits.MaxFileSize {
+		return 0, buff.budget.limitError(buff.what, "maxfilesize", fmt.Sprintf("%d bytes", buff.budget.limits.MaxFileSize))
+	}
+	if buff.budget.totalSize+size > buff.budget.limits.MaxTotalSize {
+		return 0, buff.budget.limitError(buff.what, "maxtotalsize", fmt.Sprintf("%d bytes", buff.budget.limits.MaxTotalSize))
+	}
+	return buff.Buffer.Write(p)
+}
+
+// cancel stops the buffer from being written to
+//
+func (buff *limitedBuffer) cancel() {
+	buff.mutex.Lock()
+	defer buff.mutex.Unlock()
+	buff.cancelled = true
+}
+
+// execute renders a template within the limits. A template which takes too long is abandoned
+// (as a template can't be interrupted) and its error returned straight away. The abandoned
+// template stops the next time it writes anything, but one which loops without writing keeps
+// its goroutine (and so a CPU) busy until the command finishes, as nothing can stop it
+//
+func (budget *renderBudget) execute(tmpl *template.Template, data interface{}, what string) ([]byte, error) {
+	buff := &limitedBuffer{budget: budget, what: what}
+	done := make(chan error, 1)
+	go func() {
+		done <- tmpl.Execute(buff, data)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, err
+		}
+		return buff.Bytes(), nil
+	case <-time.After(budget.limits.TemplateTimeout):
+		buff.cancel()
+		return nil, budget.limitError(what, "templatetimeout", budget.limits.TemplateTimeout)
+	}
+}
+
+// addFile counts a rendered file (or directory) against the limits
+//
+func (budget *renderBudget) addFile(what string, size int64) error {
+	budget.files++
+	if budget.files > budget.limits.MaxFiles {
+		return budget.limitError(what, "maxfiles", fmt.Sprintf("%d files", budget.limits.MaxFiles))
+	}
+	if size > budget.limits.MaxFileSize {
+		return budget.limitError(what, "maxfilesize", fmt.Sprintf("%d bytes", budget.limits.MaxFileSize))
+	}
+	budget.totalSize += size
+	if budget.totalSize > budget.limits.MaxTotalSize {
+		return budget.limitError(what, "maxtotalsize", fmt.Sprintf("%d bytes", budget.limits.MaxTotalSize))
+	}
+	return nil
+}
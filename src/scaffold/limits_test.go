@@ -0,0 +1,79 @@
+package scaffold
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+)
+
+func TestExecuteAbandonsLoopingTemplate(t *testing.T) {
+	budget := newRenderBudget(&config.PolarisScaffold{
+		Name:   "test/looping",
+		Limits: config.PolarisRenderLimits{TemplateTimeout: 50 * time.Millisecond},
+	})
+
+	// Ranging over a channel which never closes loops for ever, writing as it goes
+	//
+	items := make(chan int)
+	stop := make(chan bool)
+	defer close(stop)
+	var sent int64
+	go func() {
+		for {
+			select {
+			case items <- 1:
+				atomic.AddInt64(&sent, 1)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	tmpl := template.Must(template.New("looping").Parse("{{ range . }}looping {{ . }}\n{{ end }}"))
+	_, err := budget.execute(tmpl, items, "looping.txt")
+	if err == nil || !strings.Contains(err.Error(), "templatetimeout") {
+		t.Fatalf("expected the template to time out, got %v", err)
+	}
+
+	// The budget can be used again straight away, and the abandoned template stops writing
+	//
+	err = budget.addFile("other.txt", 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	before := atomic.LoadInt64(&sent)
+	time.Sleep(50 * time.Millisecond)
+	if after := atomic.LoadInt64(&sent); after != before {
+		t.Errorf("the abandoned template is still running (%d more items)", after-before)
+	}
+}
+
+func TestRenderDefaultWithinLimits(t *testing.T) {
+	budget := newRenderBudget(&config.PolarisScaffold{
+		Name:   "test/defaults",
+		Limits: config.PolarisRenderLimits{MaxFileSize: 16, TemplateTimeout: 50 * time.Millisecond},
+	})
+
+	spec := []config.PolarisScaffoldParameter{
+		{Name: "name", Default: "orders"},
+		{Name: "long", Default: "[[ .Parameters.name ]]-[[ .Parameters.name ]]-[[ .Parameters.name ]]"},
+	}
+	_, _, err := resolveParameters(spec, map[string]string{}, defaultValues{}, budget)
+	if err == nil || !strings.Contains(err.Error(), "Rendering default of parameter long hit the maxfilesize limit") {
+		t.Errorf("expected the default to hit the maxfilesize limit, got %v", err)
+	}
+
+	// Ranging over a channel which never sends blocks for ever, without writing anything
+	//
+	items := make(chan int)
+	_, err = renderDefault(budget, "waiting", "[[ range . ]][[ . ]][[ end ]]", items)
+	if err == nil || !strings.Contains(err.Error(), "templatetimeout") {
+		t.Errorf("expected the default to time out, got %v", err)
+	}
+	close(items)
+}
@@ -0,0 +1,145 @@
+package scaffold
+
+import (
+	"bytes"
+)
+
+// splitLines splits contents into lines, keeping the line endings
+//
+func splitLines(contents []byte) [][]byte {
+	lines := bytes.SplitAfter(contents, []byte("\n"))
+	if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// matchLines finds the longest common subsequence of two sets of lines, returning for each line
+// of a the index of the line of b it matches (or -1 where it doesn't match)
+//
+func matchLines(a [][]byte, b [][]byte) []int {
+	matches := make([]int, len(a))
+	for i := range matches {
+		matches[i] = -1
+	}
+
+	// The common prefix and suffix always match, which keeps the table small for typical edits
+	//
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && bytes.Equal(a[prefix], b[prefix]) {
+		matches[prefix] = prefix
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && bytes.Equal(a[len(a)-1-suffix], b[len(b)-1-suffix]) {
+		matches[len(a)-1-suffix] = len(b) - 1 - suffix
+		suffix++
+	}
+
+	a, b = a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if bytes.Equal(a[i], b[j]) {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case bytes.Equal(a[i], b[j]):
+			matches[prefix+i] = prefix + j
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+
+	return matches
+}
+
+// equalLines checks whether two sets of lines are the same
+//
+func equalLines(a [][]byte, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// writeLines appends lines to a buffer, making sure the last ends with a line ending when
+// something (such as a conflict marker) has to follow it
+//
+func writeLines(buff *bytes.Buffer, lines [][]byte, terminate bool) {
+	for _, line := range lines {
+		buff.Write(line)
+	}
+	if terminate && buff.Len() > 0 && buff.Bytes()[buff.Len()-1] != '\n' {
+		buff.WriteByte('\n')
+	}
+}
+
+// mergeContents merges the changes between what was generated before (base) and what is
+// generated now (theirs) into the local copy (ours), line by line. Where both have changed
+// the same lines the result holds both sides between conflict markers and conflict is true
+//
+func mergeContents(base []byte, ours []byte, theirs []byte) ([]byte, bool) {
+	baseLines, ourLines, theirLines := splitLines(base), splitLines(ours), splitLines(theirs)
+	ourMatches := matchLines(baseLines, ourLines)
+	theirMatches := matchLines(baseLines, theirLines)
+
+	var buff bytes.Buffer
+	conflict := false
+	i, a, b := 0, 0, 0
+	for i < len(baseLines) || a < len(ourLines) || b < len(theirLines) {
+		// Find the next base line which is still there on both sides
+		//
+		m, ourEnd, theirEnd := i, len(ourLines), len(theirLines)
+		for ; m < len(baseLines); m++ {
+			if ourMatches[m] >= 0 && theirMatches[m] >= 0 {
+				ourEnd, theirEnd = ourMatches[m], theirMatches[m]
+				break
+			}
+		}
+
+		if m == i && ourEnd == a && theirEnd == b && m < len(baseLines) {
+			buff.Write(baseLines[i])
+			i, a, b = i+1, a+1, b+1
+			continue
+		}
+
+		baseChunk, ourChunk, theirChunk := baseLines[i:m], ourLines[a:ourEnd], theirLines[b:theirEnd]
+		switch {
+		case equalLines(ourChunk, baseChunk):
+			writeLines(&buff, theirChunk, false)
+		case equalLines(theirChunk, baseChunk), equalLines(ourChunk, theirChunk):
+			writeLines(&buff, ourChunk, false)
+		default:
+			conflict = true
+			writeLines(&buff, nil, true)
+			buff.WriteString("<<<<<<< local\n")
+			writeLines(&buff, ourChunk, true)
+			buff.WriteString("=======\n")
+			writeLines(&buff, theirChunk, true)
+			buff.WriteString(">>>>>>> scaffold\n")
+		}
+		i, a, b = m, ourEnd, theirEnd
+	}
+
+	return buff.Bytes(), conflict
+}
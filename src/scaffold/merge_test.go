@@ -0,0 +1,95 @@
+package scaffold
+
+import (
+	"testing"
+)
+
+func TestMergeContents(t *testing.T) {
+	tests := []struct {
+		name         string
+		base         string
+		ours         string
+		theirs       string
+		want         string
+		wantConflict bool
+	}{
+		{
+			name:   "clean merge",
+			base:   "a\nb\nc\nd\n",
+			ours:   "a\nB\nc\nd\n",
+			theirs: "a\nb\nc\nD\n",
+			want:   "a\nB\nc\nD\n",
+		},
+		{
+			name:   "only the scaffold changed",
+			base:   "a\nb\n",
+			ours:   "a\nb\n",
+			theirs: "a\nB\n",
+			want:   "a\nB\n",
+		},
+		{
+			name:   "both sides making the same change",
+			base:   "a\nb\nc\n",
+			ours:   "a\nB\nc\n",
+			theirs: "a\nB\nc\n",
+			want:   "a\nB\nc\n",
+		},
+		{
+			name:         "both sides changing the same line",
+			base:         "a\nb\nc\n",
+			ours:         "a\nlocal\nc\n",
+			theirs:       "a\nscaffold\nc\n",
+			want:         "a\n<<<<<<< local\nlocal\n=======\nscaffold\n>>>>>>> scaffold\nc\n",
+			wantConflict: true,
+		},
+		{
+			name:   "insert at the end of the file",
+			base:   "a\nb\n",
+			ours:   "a\nb\nlocal\n",
+			theirs: "A\nb\n",
+			want:   "A\nb\nlocal\n",
+		},
+		{
+			name:         "both sides inserting at the end of the file",
+			base:         "a\n",
+			ours:         "a\nlocal\n",
+			theirs:       "a\nscaffold\n",
+			want:         "a\n<<<<<<< local\nlocal\n=======\nscaffold\n>>>>>>> scaffold\n",
+			wantConflict: true,
+		},
+		{
+			name:         "conflict on a last line without a line ending",
+			base:         "a\nb",
+			ours:         "a\nlocal",
+			theirs:       "a\nscaffold",
+			want:         "a\n<<<<<<< local\nlocal\n=======\nscaffold\n>>>>>>> scaffold\n",
+			wantConflict: true,
+		},
+		{
+			name:   "windows line endings",
+			base:   "a\r\nb\r\nc\r\nd\r\n",
+			ours:   "a\r\nB\r\nc\r\nd\r\n",
+			theirs: "a\r\nb\r\nc\r\nD\r\n",
+			want:   "a\r\nB\r\nc\r\nD\r\n",
+		},
+		{
+			name:   "lines removed by the scaffold",
+			base:   "a\nb\nc\nd\ne\n",
+			ours:   "A\nb\nc\nd\ne\n",
+			theirs: "a\nb\ne\n",
+			want:   "A\nb\ne\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, conflict := mergeContents([]byte(test.base), []byte(test.ours), []byte(test.theirs))
+			if string(got) != test.want {
+				t.Errorf("got\n%q\nwant\n%q", got, test.want)
+			}
+			if conflict != test.wantConflict {
+				t.Errorf("got conflict %t, want %t", conflict, test.wantConflict)
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package scaffold
+
+import (
+	"fmt"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+	"github.com/synthesis-labs/polaris-cli/src/options"
+	"github.com/synthesis-labs/polaris-cli/src/secrets"
+)
+
+// isSecret checks whether a parameter must be kept out of the project files, being either
+// marked as secret or generated
+//
+func isSecret(specParameters []config.PolarisScaffoldParameter, name string) bool {
+	for _, parameter := range specParameters {
+		if parameter.Name == name {
+			return parameter.Secret || parameter.Generate != ""
+		}
+	}
+	return false
+}
+
+// displayValue returns the value of a parameter as it may be printed
+//
+func displayValue(specParameters []config.PolarisScaffoldParameter, name string, value string) string {
+	if isSecret(specParameters, name) && !secrets.IsReference(value) {
+		return "<secret>"
+	}
+	return value
+}
+
+// secretKeyPrefix returns what the secrets of a project are kept under in the local secret store,
+// being the project's ID. A project recorded before projects had an ID is given one
+//
+func secretKeyPrefix(project *config.PolarisProject) (string, error) {
+	if project.ID == "" {
+		id, err := secrets.Generate("uuid")
+		if err != nil {
+			return "", err
+		}
+		project.ID = id
+	}
+	return project.ID, nil
+}
+
+// generateSecret generates the value of a generated parameter
+//
+func generateSecret(parameter config.PolarisScaffoldParameter) (string, error) {
+	generated, err := secrets.Generate(parameter.Generate)
+	if err != nil {
+		return "", fmt.Errorf("Unable to generate parameter %s: %s", parameter.Name, err)
+	}
+	if options.IsVerbose() {
+		fmt.Println("Generated", parameter.Generate, "for parameter", parameter.Name)
+	}
+	return generated, nil
+}
+
+// generateSecrets generates the values of the secret parameters which are generated and have been
+// left empty
+//
+func generateSecrets(specParameters []config.PolarisScaffoldParameter, parameters map[string]string) error {
+	for _, parameter := range specParameters {
+		if !isSecret(specParameters, parameter.Name) || parameters[parameter.Name] != "" || parameter.Generate == "" {
+			continue
+		}
+
+		generated, err := generateSecret(parameter)
+		if err != nil {
+			return err
+		}
+		parameters[parameter.Name] = generated
+	}
+	return nil
+}
+
+// storeSecrets generates any missing generated parameters and moves the values of all the secret
+// parameters into the local secret store, leaving references to them in the parameters. A secret
+// already in the store is only replaced when replace is set (when its parameter is being changed).
+// Otherwise a new secret is stored alongside it, as something else (such as a component which has
+// since been renamed) may still refer to it
+//
+func storeSecrets(polarisHome string, specParameters []config.PolarisScaffoldParameter, parameters map[string]string, keyPrefix string, replace bool) error {
+	err := generateSecrets(specParameters, parameters)
+	if err != nil {
+		return err
+	}
+
+	for _, parameter := range specParameters {
+		if !isSecret(specParameters, parameter.Name) {
+			continue
+		}
+
+		value := parameters[parameter.Name]
+		if secrets.IsReference(value) {
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%s", keyPrefix, parameter.Name)
+		store := secrets.Replace
+		if !replace {
+			key, err = secrets.FreeKey(polarisHome, key)
+			if err != nil {
+				return err
+			}
+			store = secrets.Store
+		}
+		reference, err := store(polarisHome, key, value)
+		if err != nil {
+			return err
+		}
+		parameters[parameter.Name] = reference
+	}
+
+	return nil
+}
+
+// resolveProjectValues returns a copy of the project with the parameter references resolved for rendering
+//
+func resolveProjectValues(polarisHome string, project *config.PolarisProject) (*config.PolarisProject, error) {
+	parameters, err := secrets.ResolveAll(polarisHome, project.Parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := *project
+	resolved.Parameters = parameters
+	return &resolved, nil
+}
+
+// resolveComponentValues returns a copy of the component with the parameter references (of both
+// the component and the project) resolved for rendering
+//
+func resolveComponentValues(polarisHome string, component *config.PolarisComponent) (*config.PolarisComponent, error) {
+	parameters, err := secrets.ResolveAll(polarisHome, component.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	projectParameters, err := secrets.ResolveAll(polarisHome, component.ProjectParameters)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := *component
+	resolved.Parameters = parameters
+	resolved.ProjectParameters = projectParameters
+	return &resolved, nil
+}
+
+// defaultValues holds what a parameter's default template is rendered with. The project parameters
+// are resolved, with the names of those which were references (to secrets or the environment) noted
+// in projectSecrets so that they are kept out of the project files in the same way
+//
+type defaultValues struct {
+	Project           string
+	Component         string
+	Parameters        map[string]string
+	ProjectParameters map[string]string
+	projectSecrets    map[string]bool
+}
+
+// componentDefaultValues sets up what the defaults of the parameters of a component (or of a feature pack
+// added to it) are rendered with
+//
+func componentDefaultValues(polarisHome string, project *config.PolarisProject, componentName string) (defaultValues, error) {
+	projectParameters, err := secrets.ResolveAll(polarisHome, project.Parameters)
+	if err != nil {
+		return defaultValues{}, err
+	}
+	projectSecrets := map[string]bool{}
+	for name, value := range project.Parameters {
+		if secrets.IsReference(value) {
+			projectSecrets[name] = true
+		}
+	}
+
+	return defaultValues{
+		Project:           project.Project,
+		Component:         componentName,
+		ProjectParameters: projectParameters,
+		projectSecrets:    projectSecrets,
+	}, nil
+}
+
+// masked returns a copy of the values with every secret among them changed, so that rendering a default
+// with both shows whether the default depends on a secret
+//
+func (values defaultValues) masked(specParameters []config.PolarisScaffoldParameter) defaultValues {
+	mask := func(parameters map[string]string, isSecret func(name string) bool) map[string]string {
+		masked := map[string]string{}
+		for name, value := range parameters {
+			if isSecret(name) {
+				value += "\x00"
+			}
+			masked[name] = value
+		}
+		return masked
+	}
+
+	masked := values
+	masked.Parameters = mask(values.Parameters, func(name string) bool { return isSecret(specParameters, name) })
+	masked.ProjectParameters = mask(values.ProjectParameters, func(name string) bool { return values.projectSecrets[name] })
+	return masked
+}
+
+// resolveParameters works out the value of every parameter, in the order they are specified:
+// the value provided, otherwise the first of its sources which has a value, otherwise its
+// default rendered as a template over the parameters before it. A generated parameter left
+// empty is generated there and then, so that the defaults after it can use it.
+//
+// The parameters are returned along with their specification, in which a parameter whose
+// default depends on a secret is marked as secret itself, so that it is kept out of the
+// project files like the secret it was rendered from. The defaults are rendered within the
+// budget of the scaffold
+//
+func resolveParameters(specParameters []config.PolarisScaffoldParameter, provided map[string]string, values defaultValues, budget *renderBudget) (map[string]string, []config.PolarisScaffoldParameter, error) {
+	resolvedSpec := append([]config.PolarisScaffoldParameter{}, specParameters...)
+	resolved := map[string]string{}
+	for paramKey, paramValue := range provided {
+		resolved[paramKey] = paramValue
+	}
+	values.Parameters = resolved
+
+	for i, parameter := range resolvedSpec {
+		value, fromDefault, err := resolveParameter(resolvedSpec, parameter, provided, values, budget)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if fromDefault && !isSecret(resolvedSpec, parameter.Name) {
+			masked, err := renderDefault(budget, parameter.Name, parameter.Default, values.masked(resolvedSpec))
+			if err != nil {
+				return nil, nil, fmt.Errorf("Unable to render default of parameter %s: %s", parameter.Name, err)
+			}
+			if masked != value {
+				resolvedSpec[i].Secret = true
+				if options.IsVerbose() {
+					fmt.Println("Parameter", parameter.Name, "is kept secret, as its default uses a secret")
+				}
+			}
+		}
+		if fromDefault && options.IsVerbose() {
+			fmt.Println("Parameter", parameter.Name, "default:", displayValue(resolvedSpec, parameter.Name, value))
+		}
+
+		if value == "" && parameter.Generate != "" {
+			value, err = generateSecret(parameter)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		resolved[parameter.Name] = value
+	}
+
+	return resolved, resolvedSpec, nil
+}
+
+// resolveParameter works out the value of a single parameter, returning whether it is the parameter's
+// default (which is left to the caller to print, once it knows whether the default is secret)
+//
+func resolveParameter(specParameters []config.PolarisScaffoldParameter, parameter config.PolarisScaffoldParameter, provided map[string]string, values defaultValues, budget *renderBudget) (string, bool, error) {
+	if value, found := provided[parameter.Name]; found {
+		if options.IsVerbose() {
+			fmt.Println("Parameter", parameter.Name, "provided:", displayValue(specParameters, parameter.Name, value))
+		}
+		return value, false, nil
+	}
+
+	for _, source := range parameter.From {
+		value, err := lookupSource(source)
+		if err != nil {
+			if options.IsVerbose() {
+				fmt.Println("Parameter", parameter.Name, "source", source, "failed:", err)
+			}
+			continue
+		}
+		if value == "" {
+			if options.IsVerbose() {
+				fmt.Println("Parameter", parameter.Name, "source", source, "has no value")
+			}
+			continue
+		}
+
+		if options.IsVerbose() {
+			fmt.Println("Parameter", parameter.Name, "from", source+":", displayValue(specParameters, parameter.Name, value))
+		}
+		return value, false, nil
+	}
+
+	value, err := renderDefault(budget, parameter.Name, parameter.Default, values)
+	if err != nil {
+		return "", false, fmt.Errorf("Unable to render default of parameter %s: %s", parameter.Name, err)
+	}
+	return value, true, nil
+}
@@ -0,0 +1,176 @@
+package scaffold
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+	"github.com/synthesis-labs/polaris-cli/src/secrets"
+)
+
+func TestResolveParametersKeepsDerivedSecrets(t *testing.T) {
+	tests := []struct {
+		name       string
+		spec       []config.PolarisScaffoldParameter
+		provided   map[string]string
+		values     defaultValues
+		wantValues map[string]string
+		wantSecret map[string]bool
+	}{
+		{
+			name: "default from a secret parameter",
+			spec: []config.PolarisScaffoldParameter{
+				{Name: "pw", Secret: true},
+				{Name: "url", Default: "db://u:[[ .Parameters.pw ]]@h"},
+			},
+			provided:   map[string]string{"pw": "hunter2"},
+			wantValues: map[string]string{"pw": "hunter2", "url": "db://u:hunter2@h"},
+			wantSecret: map[string]bool{"pw": true, "url": true},
+		},
+		{
+			name: "default from a secret of the project",
+			spec: []config.PolarisScaffoldParameter{
+				{Name: "url", Default: "db://u:[[ .ProjectParameters.pw ]]@h"},
+				{Name: "host", Default: "[[ .Project ]].[[ .ProjectParameters.domain ]]"},
+			},
+			values: defaultValues{
+				Project:           "orders",
+				ProjectParameters: map[string]string{"pw": "hunter2", "domain": "example.com"},
+				projectSecrets:    map[string]bool{"pw": true},
+			},
+			wantValues: map[string]string{"url": "db://u:hunter2@h", "host": "orders.example.com"},
+			wantSecret: map[string]bool{"url": true, "host": false},
+		},
+		{
+			name: "default from a default from a secret",
+			spec: []config.PolarisScaffoldParameter{
+				{Name: "pw", Secret: true},
+				{Name: "url", Default: "db://u:[[ .Parameters.pw ]]@h"},
+				{Name: "dsn", Default: "[[ .Parameters.url ]]/orders"},
+			},
+			provided:   map[string]string{"pw": "hunter2"},
+			wantValues: map[string]string{"pw": "hunter2", "url": "db://u:hunter2@h", "dsn": "db://u:hunter2@h/orders"},
+			wantSecret: map[string]bool{"url": true, "dsn": true},
+		},
+		{
+			name: "default which only tests a secret",
+			spec: []config.PolarisScaffoldParameter{
+				{Name: "pw", Secret: true},
+				{Name: "auth", Default: "[[ if .Parameters.pw ]]password[[ else ]]none[[ end ]]"},
+			},
+			provided:   map[string]string{"pw": "hunter2"},
+			wantValues: map[string]string{"pw": "hunter2", "auth": "password"},
+			wantSecret: map[string]bool{"auth": false},
+		},
+		{
+			name: "provided value",
+			spec: []config.PolarisScaffoldParameter{
+				{Name: "pw", Secret: true},
+				{Name: "url", Default: "db://u:[[ .Parameters.pw ]]@h"},
+			},
+			provided:   map[string]string{"pw": "hunter2", "url": "db://other"},
+			wantValues: map[string]string{"pw": "hunter2", "url": "db://other"},
+			wantSecret: map[string]bool{"url": false},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provided := test.provided
+			if provided == nil {
+				provided = map[string]string{}
+			}
+			resolved, spec, err := resolveParameters(test.spec, provided, test.values, newRenderBudget(&config.PolarisScaffold{Name: "test/scaffold"}))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			for name, want := range test.wantValues {
+				if resolved[name] != want {
+					t.Errorf("%s = %q, want %q", name, resolved[name], want)
+				}
+			}
+			for name, want := range test.wantSecret {
+				if got := isSecret(spec, name); got != want {
+					t.Errorf("isSecret(%s) = %t, want %t", name, got, want)
+				}
+			}
+			for _, parameter := range test.spec {
+				if parameter.Name == "url" && parameter.Secret {
+					t.Errorf("the scaffold's specification was changed")
+				}
+			}
+		})
+	}
+}
+
+func TestResolveParametersGeneratesBeforeDefaults(t *testing.T) {
+	spec := []config.PolarisScaffoldParameter{
+		{Name: "token", Generate: "uuid"},
+		{Name: "auth", Default: "Bearer [[ .Parameters.token ]]"},
+	}
+	resolved, resolvedSpec, err := resolveParameters(spec, map[string]string{}, defaultValues{}, newRenderBudget(&config.PolarisScaffold{Name: "test/scaffold"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(resolved["token"]) != 36 {
+		t.Errorf("token = %q, want a uuid", resolved["token"])
+	}
+	if resolved["auth"] != "Bearer "+resolved["token"] {
+		t.Errorf("auth = %q, want it to use the generated token %q", resolved["auth"], resolved["token"])
+	}
+	if !isSecret(resolvedSpec, "auth") {
+		t.Errorf("auth isn't kept secret")
+	}
+}
+
+func TestNewComponentValuesKeepsProjectSecrets(t *testing.T) {
+	polarisHome, err := ioutil.TempDir("", "polaris-parameters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(polarisHome)
+
+	reference, err := secrets.Store(polarisHome, "orders/password", "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	project := &config.PolarisProject{
+		Project:    "orders",
+		ID:         "orders",
+		Parameters: map[string]string{"password": reference},
+		Components: map[string]config.PolarisProjectComponent{},
+	}
+	componentScaffold := &config.PolarisScaffold{
+		Name: "test/component",
+		Spec: config.PolarisScaffoldSpec{
+			Parameters: []config.PolarisScaffoldParameter{
+				{Name: "url", Default: "postgres://[[ .Component ]]:[[ .ProjectParameters.password ]]@db"},
+			},
+		},
+	}
+
+	component, specParameters, err := newComponentValues(polarisHome, componentScaffold, project, map[string]string{}, "test/component", "api")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := component.ProjectParameters["password"]; got != reference {
+		t.Errorf("ProjectParameters[password] = %q, want %q", got, reference)
+	}
+
+	// The default is rendered with the secret's value, and so is kept in the secret store rather than
+	// being recorded as it is
+	//
+	err = storeSecrets(polarisHome, specParameters, component.Parameters, "orders/api", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := component.Parameters["url"]; !secrets.IsReference(got) || strings.Contains(got, "hunter2") {
+		t.Errorf("url = %q, want a reference to the secret store", got)
+	}
+	if got, _ := secrets.Resolve(polarisHome, component.Parameters["url"]); got != "postgres://api:hunter2@db" {
+		t.Errorf("url resolves to %q, want postgres://api:hunter2@db", got)
+	}
+}
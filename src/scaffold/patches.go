@@ -0,0 +1,625 @@
+package scaffold
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+	"github.com/synthesis-labs/polaris-cli/src/options"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// jsonPatchOperation is a single operation of a JSON patch (RFC 6902)
+//
+type jsonPatchOperation struct {
+	Op    string
+	Path  string
+	Value interface{}
+}
+
+// parseJSONPatchOperation reads an operation from the parsed patch (keeping the order of the keys of its value)
+//
+func parseJSONPatchOperation(element interface{}) (*jsonPatchOperation, error) {
+	fields, isMap := element.(yaml.MapSlice)
+	if !isMap {
+		return nil, errors.New("Invalid JSON patch operation")
+	}
+	operation := jsonPatchOperation{}
+	for _, field := range fields {
+		switch field.Key {
+		case "op":
+			operation.Op = fmt.Sprint(field.Value)
+		case "path":
+			operation.Path = fmt.Sprint(field.Value)
+		case "value":
+			operation.Value = field.Value
+		}
+	}
+	return &operation, nil
+}
+
+// renderPatchField renders one of the (templated) fields of a patch
+//
+func renderPatchField(partials []partial, budget *renderBudget, name string, contents string, scaffoldValues interface{}) (string, error) {
+	tmpl, err := newScaffoldTemplate(fmt.Sprintf("PolarisPatchTemplate:%s", name), contents, partials)
+	if err != nil {
+		return "", err
+	}
+	rendered, err := budget.execute(tmpl, scaffoldValues, fmt.Sprintf("patch of %s", name))
+	if err != nil {
+		return "", err
+	}
+	return string(rendered), nil
+}
+
+// renderedPatch is a patch with its fields rendered, along with the file it changes
+//
+type renderedPatch struct {
+	config.PolarisScaffoldPatch
+	TargetPath string
+}
+
+// renderPatches renders the fields of every patch of the scaffold, checking the files they change are
+// within the local path
+//
+func renderPatches(scaffold *config.PolarisScaffold, scaffoldValues interface{}, localPath string) ([]renderedPatch, error) {
+	rendered := []renderedPatch{}
+	if len(scaffold.Spec.Patches) == 0 {
+		return rendered, nil
+	}
+
+	partials, err := readPartials(scaffold)
+	if err != nil {
+		return nil, err
+	}
+	budget := newRenderBudget(scaffold)
+
+	for _, patch := range scaffold.Spec.Patches {
+		file, err := renderPatchField(partials, budget, "file", patch.File, scaffoldValues)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to render patch file %s: %s", patch.File, err)
+		}
+		patch.File = file
+		patch.Path, err = renderPatchField(partials, budget, file, patch.Path, scaffoldValues)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to render patch of %s: %s", file, err)
+		}
+		patch.Value, err = renderPatchField(partials, budget, file, patch.Value, scaffoldValues)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to render patch of %s: %s", file, err)
+		}
+
+		targetPath := filepath.Join(localPath, filepath.FromSlash(file))
+		err = checkTargetPath(localPath, targetPath)
+		if err != nil {
+			return nil, err
+		}
+		rendered = append(rendered, renderedPatch{PolarisScaffoldPatch: patch, TargetPath: targetPath})
+	}
+
+	return rendered, nil
+}
+
+// planPatches works out the new contents of every file the scaffold patches, without writing
+// anything, so that a patch which can't be applied stops the scaffold from being unpacked at all.
+// A file which the scaffold is about to write itself is patched as it will be written
+//
+func planPatches(scaffold *config.PolarisScaffold, scaffoldValues interface{}, localPath string, renderedFiles []renderedFile) (map[string][]byte, error) {
+	patches, err := renderPatches(scaffold, scaffoldValues, localPath)
+	if err != nil {
+		return nil, err
+	}
+
+	patched := map[string][]byte{}
+	for _, patch := range patches {
+		contents, found := patched[patch.TargetPath]
+		if !found {
+			contents, err = unpackedContents(renderedFiles, patch.TargetPath)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to patch %s: %s", patch.File, err)
+			}
+		}
+
+		contents, err = applyPatch(patch.PolarisScaffoldPatch, patch.TargetPath, contents, patch.Value)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to patch %s: %s", patch.File, err)
+		}
+		patched[patch.TargetPath] = contents
+	}
+
+	return patched, nil
+}
+
+// unpackedContents returns what a file will hold once the scaffold has been unpacked, being either
+// what the scaffold renders for it or what is already there
+//
+func unpackedContents(renderedFiles []renderedFile, targetPath string) ([]byte, error) {
+	for _, rendered := range renderedFiles {
+		if rendered.IsDir || filepath.Clean(rendered.TargetPath) != filepath.Clean(targetPath) {
+			continue
+		}
+		if rendered.Binary {
+			return nil, errors.New("the scaffold renders it as a binary file")
+		}
+		return rendered.Contents, nil
+	}
+	return ioutil.ReadFile(targetPath)
+}
+
+// writePatches writes the files which the patches have changed
+//
+func writePatches(patched map[string][]byte) error {
+	targetPaths := []string{}
+	for targetPath := range patched {
+		targetPaths = append(targetPaths, targetPath)
+	}
+	sort.Strings(targetPaths)
+
+	for _, targetPath := range targetPaths {
+		current, err := ioutil.ReadFile(targetPath)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(current, patched[targetPath]) {
+			continue
+		}
+
+		err = ioutil.WriteFile(targetPath, patched[targetPath], 0644)
+		if err != nil {
+			return err
+		}
+		if options.IsVerbose() {
+			fmt.Println("Patched file", targetPath)
+		}
+	}
+	return nil
+}
+
+// applyPatch applies a single patch to the contents of a file. Patches only ever add to a file:
+// what is already there (including anything the user has changed) is left as it is, and what is
+// added is spliced into the text of the file so that its comments and layout are kept
+//
+func applyPatch(patch config.PolarisScaffoldPatch, targetPath string, contents []byte, value string) ([]byte, error) {
+	if patch.Type == "insert" {
+		return insertAtMarker(contents, value, patch.Marker, patch.Position)
+	}
+
+	// The document is read twice, as the patch is applied to it in place
+	//
+	document := yaml.MapSlice{}
+	err := yaml.Unmarshal(contents, &document)
+	if err != nil {
+		return nil, err
+	}
+	originalDocument := yaml.MapSlice{}
+	err = yaml.Unmarshal(contents, &originalDocument)
+	if err != nil {
+		return nil, err
+	}
+	original, err := marshalDocument(targetPath, originalDocument)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated interface{}
+	switch patch.Type {
+	case "merge":
+		parsed, err := parsePatchValue(value)
+		if err != nil {
+			return nil, err
+		}
+		updated, err = updateAt(document, splitPath(patch.Path), func(node interface{}) (interface{}, error) {
+			return mergeNodes(node, parsed, patch.Path), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+	case "append":
+		parsed, err := parsePatchValue(value)
+		if err != nil {
+			return nil, err
+		}
+		updated, err = updateAt(document, splitPath(patch.Path), func(node interface{}) (interface{}, error) {
+			return appendNodes(node, parsed, patch.Path)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+	case "json":
+		updated, err = applyJSONPatch(document, value)
+		if err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("Unknown patch type %s (expected merge, append, json or insert)", patch.Type)
+	}
+
+	marshalled, err := marshalDocument(targetPath, updated)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(marshalled, original) {
+		return contents, nil
+	}
+	return spliceDocument(targetPath, contents, originalDocument, updated)
+}
+
+// splitPath splits a dot separated path within a document into its keys
+//
+func splitPath(documentPath string) []string {
+	if documentPath == "" {
+		return []string{}
+	}
+	return strings.Split(documentPath, ".")
+}
+
+// parsePatchValue parses the (YAML or JSON) value of a patch, keeping the order of its keys
+//
+func parsePatchValue(value string) (interface{}, error) {
+	wrapped := yaml.MapSlice{}
+	err := yaml.Unmarshal([]byte("value:\n"+indentLines(value, "  ")), &wrapped)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) == 0 {
+		return nil, nil
+	}
+	return wrapped[0].Value, nil
+}
+
+// indentLines indents every non empty line
+//
+func indentLines(s string, pad string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = pad + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// updateAt replaces the node found by following the keys (map keys or list indexes) with whatever
+// update returns, creating any maps missing along the way
+//
+func updateAt(node interface{}, keys []string, update func(node interface{}) (interface{}, error)) (interface{}, error) {
+	if len(keys) == 0 {
+		return update(node)
+	}
+
+	switch n := node.(type) {
+	case nil:
+		return updateAt(yaml.MapSlice{}, keys, update)
+
+	case yaml.MapSlice:
+		for i := range n {
+			if fmt.Sprint(n[i].Key) == keys[0] {
+				value, err := updateAt(n[i].Value, keys[1:], update)
+				if err != nil {
+					return nil, err
+				}
+				n[i].Value = value
+				return n, nil
+			}
+		}
+		value, err := updateAt(nil, keys[1:], update)
+		if err != nil {
+			return nil, err
+		}
+		return append(n, yaml.MapItem{Key: keys[0], Value: value}), nil
+
+	case []interface{}:
+		index, err := strconv.Atoi(keys[0])
+		if err != nil || index < 0 || index >= len(n) {
+			return nil, fmt.Errorf("Invalid list index %s", keys[0])
+		}
+		value, err := updateAt(n[index], keys[1:], update)
+		if err != nil {
+			return nil, err
+		}
+		n[index] = value
+		return n, nil
+	}
+
+	return nil, fmt.Errorf("Unable to find %s within a value which is not a map or list", keys[0])
+}
+
+// mergeNodes adds whatever is missing from node, never replacing what is already there
+//
+func mergeNodes(node interface{}, value interface{}, documentPath string) interface{} {
+	if node == nil {
+		return value
+	}
+
+	nodeMap, nodeIsMap := node.(yaml.MapSlice)
+	valueMap, valueIsMap := value.(yaml.MapSlice)
+	if !nodeIsMap || !valueIsMap {
+		if options.IsVerbose() && !reflect.DeepEqual(node, value) {
+			fmt.Println("Leaving", documentPath, "as it is")
+		}
+		return node
+	}
+
+	for _, item := range valueMap {
+		found := false
+		for i := range nodeMap {
+			if reflect.DeepEqual(nodeMap[i].Key, item.Key) {
+				nodeMap[i].Value = mergeNodes(nodeMap[i].Value, item.Value, strings.TrimPrefix(fmt.Sprintf("%s.%v", documentPath, item.Key), "."))
+				found = true
+				break
+			}
+		}
+		if !found {
+			nodeMap = append(nodeMap, item)
+		}
+	}
+	return nodeMap
+}
+
+// appendNodes appends the value (or each of its elements if it is a list) to the list, unless
+// it is already there
+//
+func appendNodes(node interface{}, value interface{}, documentPath string) (interface{}, error) {
+	list := []interface{}{}
+	if node != nil {
+		existing, isList := node.([]interface{})
+		if !isList {
+			return nil, fmt.Errorf("%s is not a list", documentPath)
+		}
+		list = existing
+	}
+
+	values, isList := value.([]interface{})
+	if !isList {
+		values = []interface{}{value}
+	}
+
+	for _, v := range values {
+		found := false
+		for _, existing := range list {
+			if reflect.DeepEqual(existing, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list, nil
+}
+
+// applyJSONPatch applies a JSON patch to the document. Only add and test are supported, as
+// the other operations would change what is already in the file
+//
+func applyJSONPatch(document yaml.MapSlice, value string) (interface{}, error) {
+	parsed, err := parsePatchValue(value)
+	if err != nil {
+		return nil, err
+	}
+	operations, isList := parsed.([]interface{})
+	if !isList {
+		return nil, errors.New("A JSON patch must be a list of operations")
+	}
+
+	var updated interface{} = document
+	for _, element := range operations {
+		operation, err := parseJSONPatchOperation(element)
+		if err != nil {
+			return nil, err
+		}
+
+		keys, err := splitPointer(operation.Path)
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("Unable to %s the whole document", operation.Op)
+		}
+		last := keys[len(keys)-1]
+
+		switch operation.Op {
+		case "add":
+			updated, err = updateAt(updated, keys[:len(keys)-1], func(node interface{}) (interface{}, error) {
+				return jsonPatchAdd(node, last, operation.Value, operation.Path)
+			})
+
+		case "test":
+			updated, err = updateAt(updated, keys, func(node interface{}) (interface{}, error) {
+				if !reflect.DeepEqual(node, operation.Value) {
+					return nil, fmt.Errorf("Test of %s failed", operation.Path)
+				}
+				return node, nil
+			})
+
+		default:
+			return nil, fmt.Errorf("Unsupported JSON patch operation %s (patches may only add and test)", operation.Op)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return updated, nil
+}
+
+// splitPointer splits a JSON pointer (such as /dependencies/-) into its keys
+//
+func splitPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("Invalid JSON pointer %s", pointer)
+	}
+	keys := strings.Split(pointer[1:], "/")
+	for i, key := range keys {
+		keys[i] = strings.Replace(strings.Replace(key, "~1", "/", -1), "~0", "~", -1)
+	}
+	return keys, nil
+}
+
+// jsonPatchAdd adds a value to a map (unless the key is already there) or a list (unless the value
+// is already in it). A list index of - appends to the list
+//
+func jsonPatchAdd(node interface{}, key string, value interface{}, pointer string) (interface{}, error) {
+	switch n := node.(type) {
+	case nil:
+		return yaml.MapSlice{{Key: key, Value: value}}, nil
+
+	case yaml.MapSlice:
+		for _, item := range n {
+			if fmt.Sprint(item.Key) == key {
+				if options.IsVerbose() && !reflect.DeepEqual(item.Value, value) {
+					fmt.Println("Leaving", pointer, "as it is")
+				}
+				return n, nil
+			}
+		}
+		return append(n, yaml.MapItem{Key: key, Value: value}), nil
+
+	case []interface{}:
+		for _, existing := range n {
+			if reflect.DeepEqual(existing, value) {
+				return n, nil
+			}
+		}
+		if key == "-" {
+			return append(n, value), nil
+		}
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 || index > len(n) {
+			return nil, fmt.Errorf("Invalid list index %s", key)
+		}
+		return append(n[:index], append([]interface{}{value}, n[index:]...)...), nil
+	}
+
+	return nil, fmt.Errorf("Unable to add to %s, which is not a map or list", pointer)
+}
+
+// insertAtMarker inserts the value before (or after) the first line holding the marker, unless
+// the value is already in the file
+//
+func insertAtMarker(contents []byte, value string, marker string, position string) ([]byte, error) {
+	if marker == "" {
+		return nil, errors.New("An insert patch needs a marker")
+	}
+	if position != "" && position != "before" && position != "after" {
+		return nil, fmt.Errorf("Unknown position %s (expected before or after)", position)
+	}
+	value = withNewline(value)
+	if bytes.Contains(contents, []byte(value)) {
+		return contents, nil
+	}
+
+	lines := splitLines(contents)
+	for i, line := range lines {
+		if !bytes.Contains(line, []byte(marker)) {
+			continue
+		}
+
+		if position == "after" {
+			i++
+			if !bytes.HasSuffix(line, []byte("\n")) {
+				lines[i-1] = append(append([]byte{}, line...), '\n')
+			}
+		}
+
+		var buff bytes.Buffer
+		writeLines(&buff, lines[:i], false)
+		buff.WriteString(value)
+		writeLines(&buff, lines[i:], false)
+		return buff.Bytes(), nil
+	}
+
+	return nil, fmt.Errorf("Unable to find marker %s", marker)
+}
+
+// withNewline ends the value of an insert patch with a newline, as it is inserted as whole lines
+//
+func withNewline(value string) string {
+	if !strings.HasSuffix(value, "\n") {
+		return value + "\n"
+	}
+	return value
+}
+
+// marshalDocument writes a document back out as JSON or YAML, depending on the file
+//
+func marshalDocument(targetPath string, document interface{}) ([]byte, error) {
+	if strings.ToLower(filepath.Ext(targetPath)) != ".json" {
+		return yaml.Marshal(document)
+	}
+
+	compact, err := marshalJSON(document)
+	if err != nil {
+		return nil, err
+	}
+	var buff bytes.Buffer
+	err = json.Indent(&buff, compact, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	buff.WriteString("\n")
+	return buff.Bytes(), nil
+}
+
+// marshalJSON marshals a document as JSON, keeping the order of the keys of its maps
+//
+func marshalJSON(node interface{}) ([]byte, error) {
+	var buff bytes.Buffer
+	switch n := node.(type) {
+	case yaml.MapSlice:
+		buff.WriteString("{")
+		for i, item := range n {
+			if i > 0 {
+				buff.WriteString(",")
+			}
+			key, err := json.Marshal(fmt.Sprint(item.Key))
+			if err != nil {
+				return nil, err
+			}
+			value, err := marshalJSON(item.Value)
+			if err != nil {
+				return nil, err
+			}
+			buff.Write(key)
+			buff.WriteString(":")
+			buff.Write(value)
+		}
+		buff.WriteString("}")
+
+	case []interface{}:
+		buff.WriteString("[")
+		for i, element := range n {
+			if i > 0 {
+				buff.WriteString(",")
+			}
+			value, err := marshalJSON(element)
+			if err != nil {
+				return nil, err
+			}
+			buff.Write(value)
+		}
+		buff.WriteString("]")
+
+	default:
+		value, err := json.Marshal(n)
+		if err != nil {
+			return nil, err
+		}
+		buff.Write(value)
+	}
+	return buff.Bytes(), nil
+}
@@ -0,0 +1,237 @@
+package scaffold
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+)
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		patch    config.PolarisScaffoldPatch
+		value    string
+		contents string
+		want     string
+		wantErr  string
+	}{
+		{
+			name:  "merge keeps comments and layout",
+			file:  "values.yaml",
+			patch: config.PolarisScaffoldPatch{Type: "merge", Path: "api"},
+			value: "enabled: true\nport: 8080\n",
+			contents: "# Values for the chart\n\nreplicas: 1   # how many\n\n" +
+				"api:\n    image: 'api:latest'\n    # the port\n    port: 80\n\nweb: {}\n",
+			want: "# Values for the chart\n\nreplicas: 1   # how many\n\n" +
+				"api:\n    image: 'api:latest'\n    # the port\n    port: 80\n    enabled: true\n\nweb: {}\n",
+		},
+		{
+			name:     "merge adds a missing map",
+			file:     "values.yaml",
+			patch:    config.PolarisScaffoldPatch{Type: "merge", Path: "api"},
+			value:    "enabled: true\n",
+			contents: "# top\nweb:\n  enabled: false\n",
+			want:     "# top\nweb:\n  enabled: false\napi:\n  enabled: true\n",
+		},
+		{
+			name:     "merge into a null",
+			file:     "values.yaml",
+			patch:    config.PolarisScaffoldPatch{Type: "merge", Path: "api"},
+			value:    "enabled: true\n",
+			contents: "api:   # filled in by components\nweb: 1\n",
+			want:     "api: # filled in by components\n  enabled: true\nweb: 1\n",
+		},
+		{
+			name:     "merge a scalar into a null",
+			file:     "values.yaml",
+			patch:    config.PolarisScaffoldPatch{Type: "merge", Path: "api"},
+			value:    "enabled: true\n",
+			contents: "api:\n  enabled: ~  # unset\n",
+			want:     "api:\n  enabled: true # unset\n",
+		},
+		{
+			name:     "merge leaves what is there",
+			file:     "values.yaml",
+			patch:    config.PolarisScaffoldPatch{Type: "merge", Path: "api"},
+			value:    "enabled: true\n",
+			contents: "api:\n  enabled:   false  # off\n",
+			want:     "api:\n  enabled:   false  # off\n",
+		},
+		{
+			name:     "append to a list at the indentation of its key",
+			file:     "requirements.yaml",
+			patch:    config.PolarisScaffoldPatch{Type: "append", Path: "dependencies"},
+			value:    "- name: api\n  version: 0.1.0\n",
+			contents: "dependencies:\n# the web\n- name: web\n  version: \"0.1.0\"\n\nother: true\n",
+			want:     "dependencies:\n# the web\n- name: web\n  version: \"0.1.0\"\n- name: api\n  version: 0.1.0\n\nother: true\n",
+		},
+		{
+			name:     "append within a list element",
+			file:     "values.yaml",
+			patch:    config.PolarisScaffoldPatch{Type: "append", Path: "routes.0.hosts"},
+			value:    "b.example.com",
+			contents: "routes:\n  - name: main\n    hosts:\n      - a.example.com\n",
+			want:     "routes:\n  - name: main\n    hosts:\n      - a.example.com\n      - b.example.com\n",
+		},
+		{
+			name:     "append is not repeated",
+			file:     "requirements.yaml",
+			patch:    config.PolarisScaffoldPatch{Type: "append", Path: "dependencies"},
+			value:    "- web\n",
+			contents: "dependencies:\n  - web\n",
+			want:     "dependencies:\n  - web\n",
+		},
+		{
+			name:     "append keeps windows line endings",
+			file:     "requirements.yaml",
+			patch:    config.PolarisScaffoldPatch{Type: "append", Path: "dependencies"},
+			value:    "- api\n",
+			contents: "dependencies:\r\n  - web\r\n",
+			want:     "dependencies:\r\n  - web\r\n  - api\r\n",
+		},
+		{
+			name:     "append to a flow list is refused",
+			file:     "requirements.yaml",
+			patch:    config.PolarisScaffoldPatch{Type: "append", Path: "dependencies"},
+			value:    "- api\n",
+			contents: "dependencies: [web]\n",
+			wantErr:  "without writing out the whole file",
+		},
+		{
+			name:     "append to a value which isn't a list",
+			file:     "requirements.yaml",
+			patch:    config.PolarisScaffoldPatch{Type: "append", Path: "dependencies"},
+			value:    "- api\n",
+			contents: "dependencies: web\n",
+			wantErr:  "is not a list",
+		},
+		{
+			name:     "json add to an array",
+			file:     "package.json",
+			patch:    config.PolarisScaffoldPatch{Type: "json"},
+			value:    "- op: add\n  path: /workspaces/-\n  value: images/api\n",
+			contents: "{\n    \"name\": \"shop\",\n    \"workspaces\": [\n        \"images/web\"\n    ]\n}\n",
+			want:     "{\n    \"name\": \"shop\",\n    \"workspaces\": [\n        \"images/web\",\n        \"images/api\"\n    ]\n}\n",
+		},
+		{
+			name:     "json add an object to an object",
+			file:     "package.json",
+			patch:    config.PolarisScaffoldPatch{Type: "json"},
+			value:    "- op: add\n  path: /scripts\n  value:\n    build: make\n",
+			contents: "{\n  \"name\": \"shop\"\n}\n",
+			want:     "{\n  \"name\": \"shop\",\n  \"scripts\": {\n    \"build\": \"make\"\n  }\n}\n",
+		},
+		{
+			name:     "json add to an empty array",
+			file:     "package.json",
+			patch:    config.PolarisScaffoldPatch{Type: "json"},
+			value:    "- op: add\n  path: /workspaces/0\n  value: images/api\n",
+			contents: "{\n  \"workspaces\": []\n}\n",
+			want:     "{\n  \"workspaces\": [\n    \"images/api\"\n  ]\n}\n",
+		},
+		{
+			name:     "json add on one line",
+			file:     "package.json",
+			patch:    config.PolarisScaffoldPatch{Type: "json"},
+			value:    "- op: add\n  path: /workspaces/0\n  value: images/api\n",
+			contents: "{\"workspaces\": [\"images/web\"]}\n",
+			want:     "{\"workspaces\": [\"images/api\", \"images/web\"]}\n",
+		},
+		{
+			name:     "json test failing",
+			file:     "package.json",
+			patch:    config.PolarisScaffoldPatch{Type: "json"},
+			value:    "- op: test\n  path: /name\n  value: other\n",
+			contents: "{\"name\": \"shop\"}\n",
+			wantErr:  "Test of /name failed",
+		},
+		{
+			name:     "json remove is refused",
+			file:     "package.json",
+			patch:    config.PolarisScaffoldPatch{Type: "json"},
+			value:    "- op: remove\n  path: /name\n",
+			contents: "{\"name\": \"shop\"}\n",
+			wantErr:  "Unsupported JSON patch operation remove",
+		},
+		{
+			name:     "insert after a marker",
+			file:     "NOTES.txt",
+			patch:    config.PolarisScaffoldPatch{Type: "insert", Marker: "# components", Position: "after"},
+			value:    "- api",
+			contents: "Components:\n# components\n- web\n",
+			want:     "Components:\n# components\n- api\n- web\n",
+		},
+		{
+			name:     "insert before a marker on the last line",
+			file:     "NOTES.txt",
+			patch:    config.PolarisScaffoldPatch{Type: "insert", Marker: "# end"},
+			value:    "- api\n",
+			contents: "- web\n# end",
+			want:     "- web\n- api\n# end",
+		},
+		{
+			name:     "insert is not repeated",
+			file:     "NOTES.txt",
+			patch:    config.PolarisScaffoldPatch{Type: "insert", Marker: "# end"},
+			value:    "- api",
+			contents: "- api\n# end\n",
+			want:     "- api\n# end\n",
+		},
+		{
+			name:     "insert without the marker",
+			file:     "NOTES.txt",
+			patch:    config.PolarisScaffoldPatch{Type: "insert", Marker: "# end"},
+			value:    "- api",
+			contents: "- web\n",
+			wantErr:  "Unable to find marker # end",
+		},
+		{
+			name:     "unknown type",
+			file:     "values.yaml",
+			patch:    config.PolarisScaffoldPatch{Type: "replace"},
+			contents: "a: 1\n",
+			wantErr:  "Unknown patch type replace",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := applyPatch(test.patch, test.file, []byte(test.contents), test.value)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v (%q)", test.wantErr, err, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if string(got) != test.want {
+				t.Errorf("got\n%q\nwant\n%q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPlanPatchesAgainstRenderedFiles(t *testing.T) {
+	scaffold := &config.PolarisScaffold{
+		Name: "test/component",
+		Spec: config.PolarisScaffoldSpec{Patches: []config.PolarisScaffoldPatch{
+			{File: "values.yaml", Type: "merge", Path: "api", Value: "enabled: true\n"},
+		}},
+	}
+	renderedFiles := []renderedFile{
+		{TargetPath: "./values.yaml", Contents: []byte("# rendered\napi:\n  port: 80\n")},
+	}
+
+	patched, err := planPatches(scaffold, nil, ".", renderedFiles)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := "# rendered\napi:\n  port: 80\n  enabled: true\n"
+	if got := string(patched["values.yaml"]); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
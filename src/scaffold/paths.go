@@ -0,0 +1,91 @@
+package scaffold
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// isWithin checks whether a (clean) path is the root or somewhere beneath it
+//
+func isWithin(root string, target string) bool {
+	rel, err := filepath.Rel(root, target)
+	if err != nil || filepath.IsAbs(rel) {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// checkTargetPath makes sure a rendered path stays within the root it is being written to. The
+// path must not lead out of the root, and nothing already along it may be a symbolic link to
+// somewhere outside the root (as it would be followed when writing)
+//
+func checkTargetPath(root string, targetPath string) error {
+	root = filepath.Clean(root)
+	if !isWithin(root, filepath.Clean(targetPath)) {
+		return fmt.Errorf("Rendered path %s is outside of %s", targetPath, root)
+	}
+
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(root, filepath.Clean(targetPath))
+	if err != nil || rel == "." {
+		return err
+	}
+
+	current := root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+
+		resolved, err := filepath.EvalSymlinks(current)
+		if err != nil {
+			return fmt.Errorf("Rendered path %s leads through the symbolic link %s, which can't be resolved", targetPath, current)
+		}
+		if !isWithin(resolvedRoot, resolved) {
+			return fmt.Errorf("Rendered path %s leads through the symbolic link %s to %s, which is outside of %s", targetPath, current, resolved, root)
+		}
+	}
+
+	return nil
+}
+
+// checkSourcePath makes sure a file of a scaffold is read from within the root of the scaffold. The
+// file may be a symbolic link, which would be followed when reading it, so it must not lead outside
+//
+func checkSourcePath(root string, sourcePath string) error {
+	info, err := os.Lstat(sourcePath)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return nil
+	}
+
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+	resolved, err := filepath.EvalSymlinks(sourcePath)
+	if err != nil {
+		return fmt.Errorf("Scaffold file %s is a symbolic link which can't be resolved", sourcePath)
+	}
+	if !isWithin(resolvedRoot, resolved) {
+		return fmt.Errorf("Scaffold file %s is a symbolic link to %s, which is outside of %s", sourcePath, resolved, root)
+	}
+	return nil
+}
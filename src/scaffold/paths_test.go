@@ -0,0 +1,128 @@
+package scaffold
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsWithin(t *testing.T) {
+	tests := []struct {
+		root   string
+		target string
+		want   bool
+	}{
+		{"project", "project", true},
+		{"project", "project/values.yaml", true},
+		{"project", "project/a/../b", true},
+		{"project", "project/..", false},
+		{"project", "project/../other", false},
+		{"project", "projectx/values.yaml", false},
+		{"project", "project/..values.yaml", true},
+		{"/tmp/project", "/tmp/project/a", true},
+		{"/tmp/project", "/tmp/other", false},
+		{"/tmp/project", "relative", false},
+	}
+
+	for _, test := range tests {
+		if got := isWithin(test.root, filepath.Clean(test.target)); got != test.want {
+			t.Errorf("isWithin(%q, %q) = %t, want %t", test.root, test.target, got, test.want)
+		}
+	}
+}
+
+func TestCheckTargetPath(t *testing.T) {
+	directory, err := ioutil.TempDir("", "polaris-paths")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+
+	root := filepath.Join(directory, "project")
+	outside := filepath.Join(directory, "outside")
+	for _, path := range []string{filepath.Join(root, "charts"), outside} {
+		if err := os.MkdirAll(path, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "charts"), filepath.Join(root, "inside")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(directory, "missing"), filepath.Join(root, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		targetPath string
+		wantErr    string
+	}{
+		{"a new file", "values.yaml", ""},
+		{"a new directory", "new/values.yaml", ""},
+		{"an existing directory", "charts/values.yaml", ""},
+		{"leading out", "../outside/values.yaml", "is outside of"},
+		{"through a link outside", "escape/values.yaml", "which is outside of"},
+		{"through a link inside", "inside/values.yaml", ""},
+		{"through a dangling link", "dangling/values.yaml", "can't be resolved"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkTargetPath(root, filepath.Join(root, test.targetPath))
+			if test.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+				t.Fatalf("expected an error containing %q, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCheckSourcePath(t *testing.T) {
+	directory, err := ioutil.TempDir("", "polaris-paths")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+
+	root := filepath.Join(directory, "scaffold")
+	if err := os.MkdirAll(root, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{filepath.Join(root, "values.yaml"), filepath.Join(directory, "secret")} {
+		if err := ioutil.WriteFile(file, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("values.yaml", filepath.Join(root, "inside.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../secret", filepath.Join(root, "outside.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file    string
+		wantErr string
+	}{
+		{"values.yaml", ""},
+		{"inside.yaml", ""},
+		{"outside.yaml", "which is outside of"},
+	}
+
+	for _, test := range tests {
+		err := checkSourcePath(root, filepath.Join(root, test.file))
+		if test.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %s", test.file, err)
+		}
+		if test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+			t.Errorf("%s: expected an error containing %q, got %v", test.file, test.wantErr, err)
+		}
+	}
+}
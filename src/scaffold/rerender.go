@@ -0,0 +1,841 @@
+package scaffold
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+	"github.com/synthesis-labs/polaris-cli/src/options"
+)
+
+// FileChange is a change made to a file of the project when it was rendered again: OldPath is empty
+// for a file which was created and NewPath for one which was removed. The contents aren't kept for
+// binary files
+//
+type FileChange struct {
+	OldPath string
+	NewPath string
+	Before  []byte
+	After   []byte
+	Binary  bool
+}
+
+// RerenderReport records what happened to the files of a scaffold when it was rendered again. For a
+// dry run nothing is changed, and the report records what would have happened instead
+//
+type RerenderReport struct {
+	DryRun     bool
+	Changes    []FileChange
+	Updated    []string
+	Moved      []string
+	Created    []string
+	Removed    []string
+	Merged     []string
+	Conflicted []string
+	Skipped    []string
+	Patched    []string
+	Unpatched  []string
+}
+
+// renderPlan renders every file of the scaffold without writing anything, keyed by rendering
+//
+func renderPlan(scaffold *config.PolarisScaffold, scaffoldValues interface{}, localPath string) (map[string]renderedFile, error) {
+	plan := map[string]renderedFile{}
+	err := renderScaffold(scaffold, scaffoldValues, localPath, func(rendered renderedFile) error {
+		plan[rendered.Key] = rendered
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// rerender moves the project from what the old scaffold rendered with the old values to what the
+// new scaffold renders with the new values (the scaffolds are the same unless it is being upgraded).
+// Generated files which have not been edited locally are updated (or moved) directly, while the
+// changes to files which have been edited are merged into them
+//
+func rerender(oldScaffold *config.PolarisScaffold, newScaffold *config.PolarisScaffold, oldValues interface{}, newValues interface{}, localPath string, report *RerenderReport) error {
+	oldPlan, err := renderPlan(oldScaffold, oldValues, localPath)
+	if err != nil {
+		return err
+	}
+	newPlan, err := renderPlan(newScaffold, newValues, localPath)
+	if err != nil {
+		return err
+	}
+
+	// A file rendered to the same place from a different source (which an upgrade of the scaffold may
+	// have renamed) is still the same file
+	//
+	oldTargets := map[string]string{}
+	for key, oldFile := range oldPlan {
+		if _, found := newPlan[key]; !found {
+			oldTargets[filepath.Clean(oldFile.TargetPath)] = key
+		}
+	}
+	renamed := map[string]string{}
+	for key, newFile := range newPlan {
+		if _, found := oldPlan[key]; found {
+			continue
+		}
+		if oldKey, found := oldTargets[filepath.Clean(newFile.TargetPath)]; found && oldPlan[oldKey].IsDir == newFile.IsDir {
+			renamed[key] = oldKey
+		}
+	}
+	for key, oldKey := range renamed {
+		newPlan[oldKey] = newPlan[key]
+		delete(newPlan, key)
+	}
+
+	keys := []string{}
+	for key := range oldPlan {
+		keys = append(keys, key)
+	}
+	for key := range newPlan {
+		if _, found := oldPlan[key]; !found {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	// Directories first, so the files have somewhere to go
+	//
+	oldDirectories := []string{}
+	for _, key := range keys {
+		oldFile, hadOld := oldPlan[key]
+		newFile, hasNew := newPlan[key]
+		if hadOld && oldFile.IsDir {
+			oldDirectories = append(oldDirectories, oldFile.TargetPath)
+		}
+		if hasNew && newFile.IsDir && !report.DryRun {
+			err := os.MkdirAll(newFile.TargetPath, os.ModePerm)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, key := range keys {
+		oldFile, hadOld := oldPlan[key]
+		newFile, hasNew := newPlan[key]
+		if (hadOld && oldFile.IsDir) || (hasNew && newFile.IsDir) {
+			continue
+		}
+
+		switch {
+		case hadOld && hasNew:
+			err = rerenderFile(oldFile, newFile, report)
+		case hasNew:
+			err = rerenderCreate(newFile, report)
+		case hadOld:
+			err = rerenderRemove(oldFile, report)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	err = rerenderPatches(oldScaffold, newScaffold, oldValues, newValues, localPath, report)
+	if err != nil {
+		return err
+	}
+
+	// Tidy up the directories which are now empty, deepest first
+	//
+	sort.Sort(sort.Reverse(sort.StringSlice(oldDirectories)))
+	for _, directory := range oldDirectories {
+		if report.DryRun {
+			break
+		}
+		if entries, err := ioutil.ReadDir(directory); err == nil && len(entries) == 0 {
+			os.Remove(directory)
+		}
+	}
+
+	return nil
+}
+
+// rerenderFile updates a file which is generated both before and after
+//
+func rerenderFile(oldFile renderedFile, newFile renderedFile, report *RerenderReport) error {
+	if oldFile.Binary || newFile.Binary {
+		return rerenderBinary(oldFile, newFile, report)
+	}
+
+	current, err := ioutil.ReadFile(oldFile.TargetPath)
+	if os.IsNotExist(err) {
+		report.Skipped = append(report.Skipped, fmt.Sprintf("%s (removed locally)", oldFile.TargetPath))
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	moved := filepath.Clean(oldFile.TargetPath) != filepath.Clean(newFile.TargetPath)
+	changed := !bytes.Equal(oldFile.Contents, newFile.Contents)
+	if !moved && !changed {
+		return nil
+	}
+
+	if moved {
+		if _, err := os.Stat(newFile.TargetPath); !os.IsNotExist(err) {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("%s (%s already exists)", oldFile.TargetPath, newFile.TargetPath))
+			return nil
+		}
+	}
+
+	// The changes to what is generated are merged into a locally edited file
+	//
+	contents := newFile.Contents
+	merged := false
+	if !bytes.Equal(current, oldFile.Contents) {
+		if changed {
+			if isBinary(current) {
+				report.Skipped = append(report.Skipped, fmt.Sprintf("%s (edited locally)", oldFile.TargetPath))
+				return nil
+			}
+
+			var conflict bool
+			contents, conflict = mergeContents(oldFile.Contents, current, newFile.Contents)
+			if conflict {
+				report.Conflicted = append(report.Conflicted, newFile.TargetPath)
+			} else {
+				report.Merged = append(report.Merged, newFile.TargetPath)
+			}
+			merged = true
+		} else {
+			contents = current
+		}
+	}
+
+	report.Changes = append(report.Changes, FileChange{OldPath: oldFile.TargetPath, NewPath: newFile.TargetPath, Before: current, After: contents})
+	if !report.DryRun {
+		err = ioutil.WriteFile(newFile.TargetPath, contents, 0644)
+		if err != nil {
+			return err
+		}
+		if moved {
+			err = os.Remove(oldFile.TargetPath)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if moved {
+		report.Moved = append(report.Moved, fmt.Sprintf("%s -> %s", oldFile.TargetPath, newFile.TargetPath))
+	} else if !merged {
+		report.Updated = append(report.Updated, newFile.TargetPath)
+	}
+	if options.IsVerbose() && !report.DryRun {
+		fmt.Println("Rendered", newFile.SourcePath, "to", newFile.TargetPath)
+	}
+	return nil
+}
+
+// rerenderBinary moves a binary file, which is copied from the scaffold as it is (so only its path can change)
+//
+func rerenderBinary(oldFile renderedFile, newFile renderedFile, report *RerenderReport) error {
+	if filepath.Clean(oldFile.TargetPath) == filepath.Clean(newFile.TargetPath) {
+		return nil
+	}
+	if _, err := os.Stat(oldFile.TargetPath); os.IsNotExist(err) {
+		report.Skipped = append(report.Skipped, fmt.Sprintf("%s (removed locally)", oldFile.TargetPath))
+		return nil
+	}
+	if _, err := os.Stat(newFile.TargetPath); !os.IsNotExist(err) {
+		report.Skipped = append(report.Skipped, fmt.Sprintf("%s (%s already exists)", oldFile.TargetPath, newFile.TargetPath))
+		return nil
+	}
+
+	report.Changes = append(report.Changes, FileChange{OldPath: oldFile.TargetPath, NewPath: newFile.TargetPath, Binary: true})
+	if !report.DryRun {
+		err := os.Rename(oldFile.TargetPath, newFile.TargetPath)
+		if err != nil {
+			return err
+		}
+	}
+	report.Moved = append(report.Moved, fmt.Sprintf("%s -> %s", oldFile.TargetPath, newFile.TargetPath))
+	return nil
+}
+
+// rerenderCreate writes a file which is only generated after
+//
+func rerenderCreate(newFile renderedFile, report *RerenderReport) error {
+	if _, err := os.Stat(newFile.TargetPath); !os.IsNotExist(err) {
+		report.Skipped = append(report.Skipped, fmt.Sprintf("%s (already exists)", newFile.TargetPath))
+		return nil
+	}
+	report.Changes = append(report.Changes, FileChange{NewPath: newFile.TargetPath, After: newFile.Contents, Binary: newFile.Binary})
+	if !report.DryRun {
+		err := writeRenderedFile(newFile)
+		if err != nil {
+			return err
+		}
+	}
+	report.Created = append(report.Created, newFile.TargetPath)
+	return nil
+}
+
+// rerenderRemove removes a file which was only generated before, unless it has been edited
+//
+func rerenderRemove(oldFile renderedFile, report *RerenderReport) error {
+	if _, err := os.Stat(oldFile.TargetPath); os.IsNotExist(err) {
+		return nil
+	}
+
+	var unchanged bool
+	if oldFile.Binary {
+		same, err := sameFileContents(oldFile.TargetPath, oldFile.SourcePath)
+		if err != nil {
+			return err
+		}
+		unchanged = same
+	} else {
+		current, err := ioutil.ReadFile(oldFile.TargetPath)
+		if err != nil {
+			return err
+		}
+		unchanged = bytes.Equal(current, oldFile.Contents)
+	}
+	if !unchanged {
+		report.Skipped = append(report.Skipped, fmt.Sprintf("%s (edited locally, no longer generated)", oldFile.TargetPath))
+		return nil
+	}
+	report.Changes = append(report.Changes, FileChange{OldPath: oldFile.TargetPath, Before: oldFile.Contents, Binary: oldFile.Binary})
+	if !report.DryRun {
+		err := os.Remove(oldFile.TargetPath)
+		if err != nil {
+			return err
+		}
+	}
+	report.Removed = append(report.Removed, oldFile.TargetPath)
+	return nil
+}
+
+// patchedFiles holds the files which are being patched again, as they are changed
+//
+type patchedFiles struct {
+	report   *RerenderReport
+	contents map[string][]byte
+	original map[string][]byte
+	paths    []string
+}
+
+// read returns what the file holds now (or would, for a dry run), and whether it exists at all
+//
+func (files *patchedFiles) read(targetPath string) ([]byte, bool, error) {
+	targetPath = filepath.Clean(targetPath)
+	if contents, found := files.contents[targetPath]; found {
+		return contents, true, nil
+	}
+
+	var contents []byte
+	found := false
+	if files.report.DryRun {
+		for i := len(files.report.Changes) - 1; i >= 0 && !found; i-- {
+			change := files.report.Changes[i]
+			if change.NewPath != "" && filepath.Clean(change.NewPath) == targetPath {
+				if change.Binary {
+					return nil, false, nil
+				}
+				contents, found = change.After, true
+			} else if change.OldPath != "" && filepath.Clean(change.OldPath) == targetPath {
+				return nil, false, nil
+			}
+		}
+	}
+	if !found {
+		read, err := ioutil.ReadFile(targetPath)
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		} else if err != nil {
+			return nil, false, err
+		}
+		contents = read
+	}
+
+	files.contents[targetPath] = contents
+	files.original[targetPath] = contents
+	files.paths = append(files.paths, targetPath)
+	return contents, true, nil
+}
+
+// write writes the files which have been changed, recording them in the report
+//
+func (files *patchedFiles) write() error {
+	for _, targetPath := range files.paths {
+		before, after := files.original[targetPath], files.contents[targetPath]
+		if bytes.Equal(before, after) {
+			continue
+		}
+
+		files.report.Changes = append(files.report.Changes, FileChange{OldPath: targetPath, NewPath: targetPath, Before: before, After: after})
+		files.report.Patched = append(files.report.Patched, targetPath)
+		if files.report.DryRun {
+			continue
+		}
+		err := ioutil.WriteFile(targetPath, after, 0644)
+		if err != nil {
+			return err
+		}
+		if options.IsVerbose() {
+			fmt.Println("Patched file", targetPath)
+		}
+	}
+	return nil
+}
+
+// describePatch describes a patch for the report
+//
+func describePatch(patch renderedPatch) string {
+	switch patch.Type {
+	case "insert":
+		return fmt.Sprintf("insert patch at marker %s", patch.Marker)
+	case "json":
+		return "json patch"
+	}
+	return fmt.Sprintf("%s patch at %s", patch.Type, patch.Path)
+}
+
+// rerenderPatches makes the patches of the new scaffold (rendered with the new values) in place of those
+// of the old one. As patches only add to a file, what an old patch added can't simply be taken out again:
+// a line inserted at a marker is swapped for the new one, but anything else it added is left for the user
+// to change by hand, and the new patch isn't made alongside it. Patches are paired up by their position
+// in the scaffold, as long as they are of the same type and change the same file
+//
+func rerenderPatches(oldScaffold *config.PolarisScaffold, newScaffold *config.PolarisScaffold, oldValues interface{}, newValues interface{}, localPath string, report *RerenderReport) error {
+	oldPatches, err := renderPatches(oldScaffold, oldValues, localPath)
+	if err != nil {
+		return err
+	}
+	newPatches, err := renderPatches(newScaffold, newValues, localPath)
+	if err != nil {
+		return err
+	}
+
+	files := &patchedFiles{report: report, contents: map[string][]byte{}, original: map[string][]byte{}}
+	for i := 0; i < len(oldPatches) || i < len(newPatches); i++ {
+		var oldPatch, newPatch *renderedPatch
+		if i < len(oldPatches) {
+			oldPatch = &oldPatches[i]
+		}
+		if i < len(newPatches) {
+			newPatch = &newPatches[i]
+		}
+
+		if oldPatch != nil && newPatch != nil {
+			oldSpec, newSpec := oldScaffold.Spec.Patches[i], newScaffold.Spec.Patches[i]
+			if oldSpec.Type != newSpec.Type || oldSpec.File != newSpec.File {
+				err = rerenderPatch(oldPatch, nil, files)
+				if err != nil {
+					return err
+				}
+				oldPatch = nil
+			}
+		}
+		err = rerenderPatch(oldPatch, newPatch, files)
+		if err != nil {
+			return err
+		}
+	}
+
+	return files.write()
+}
+
+// rerenderPatch makes a patch again, either of which may be missing
+//
+func rerenderPatch(oldPatch *renderedPatch, newPatch *renderedPatch, files *patchedFiles) error {
+	if oldPatch != nil && newPatch != nil && *oldPatch == *newPatch {
+		return nil
+	}
+	report := files.report
+
+	// What the old patch added is still there if making it again changes nothing
+	//
+	stillThere := false
+	if oldPatch != nil {
+		contents, found, err := files.read(oldPatch.TargetPath)
+		if err != nil {
+			return err
+		}
+		if found {
+			patched, err := applyPatch(oldPatch.PolarisScaffoldPatch, oldPatch.TargetPath, contents, oldPatch.Value)
+			stillThere = err == nil && bytes.Equal(patched, contents)
+		}
+	}
+
+	if newPatch == nil {
+		if stillThere {
+			report.Unpatched = append(report.Unpatched, fmt.Sprintf("%s (%s is no longer made, but what it added is still there)", oldPatch.TargetPath, describePatch(*oldPatch)))
+		}
+		return nil
+	}
+
+	contents, found, err := files.read(newPatch.TargetPath)
+	if err != nil {
+		return err
+	}
+	if !found {
+		report.Unpatched = append(report.Unpatched, fmt.Sprintf("%s (%s can't be made, as the file doesn't exist)", newPatch.TargetPath, describePatch(*newPatch)))
+		return nil
+	}
+
+	if stillThere {
+		oldLine, newLine := withNewline(oldPatch.Value), withNewline(newPatch.Value)
+		sameFile := filepath.Clean(oldPatch.TargetPath) == filepath.Clean(newPatch.TargetPath)
+		if newPatch.Type == "insert" && sameFile && !bytes.Contains(contents, []byte(newLine)) {
+			files.contents[filepath.Clean(newPatch.TargetPath)] = bytes.Replace(contents, []byte(oldLine), []byte(newLine), 1)
+			return nil
+		}
+		report.Unpatched = append(report.Unpatched, fmt.Sprintf("%s (%s: what it added for the old values is still there, so change it by hand)", newPatch.TargetPath, describePatch(*newPatch)))
+		return nil
+	}
+
+	patched, err := applyPatch(newPatch.PolarisScaffoldPatch, newPatch.TargetPath, contents, newPatch.Value)
+	if err != nil {
+		report.Unpatched = append(report.Unpatched, fmt.Sprintf("%s (%s can't be made: %s)", newPatch.TargetPath, describePatch(*newPatch), err))
+		return nil
+	}
+	files.contents[filepath.Clean(newPatch.TargetPath)] = patched
+	return nil
+}
+
+// PrintRerenderReport prints what happened to each file (or what would have, for a dry run)
+//
+func PrintRerenderReport(report *RerenderReport) {
+	sections := []struct {
+		title       string
+		dryRunTitle string
+		files       []string
+	}{
+		{"Updated", "Would be updated", report.Updated},
+		{"Moved", "Would be moved", report.Moved},
+		{"Created", "Would be created", report.Created},
+		{"Removed", "Would be removed", report.Removed},
+		{"Merged with local edits", "Would be merged with local edits", report.Merged},
+		{"Merged with conflicts (resolve these by hand)", "Would be merged with conflicts", report.Conflicted},
+		{"Not updated (update these by hand)", "Would not be updated", report.Skipped},
+		{"Patched", "Would be patched", report.Patched},
+		{"Patches not made again (make these by hand)", "Patches which would not be made again", report.Unpatched},
+	}
+	for _, section := range sections {
+		if len(section.files) == 0 {
+			continue
+		}
+		if report.DryRun {
+			fmt.Println(section.dryRunTitle + ":")
+		} else {
+			fmt.Println(section.title + ":")
+		}
+		for _, file := range section.files {
+			fmt.Println(" -", file)
+		}
+	}
+}
+
+// recordedComponentValues sets up the component object from what was recorded in the project
+//
+func recordedComponentValues(project *config.PolarisProject, componentName string) (*config.PolarisComponent, error) {
+	recorded, found := project.Components[componentName]
+	if !found {
+		return nil, fmt.Errorf("Component %s is not recorded in polaris-project.yaml", componentName)
+	}
+
+	return &config.PolarisComponent{
+		Project:           project.Project,
+		Component:         componentName,
+		Parameters:        recorded.Parameters,
+		ProjectParameters: project.Parameters,
+		ProjectScaffold:   project.Scaffold,
+		ComponentScaffold: recorded.Scaffold,
+	}, nil
+}
+
+// checkComponentsRecorded makes sure the project records its components, which projects generated by older
+// versions of polaris don't. Their components would otherwise be silently left as they are
+//
+func checkComponentsRecorded(project *config.PolarisProject) error {
+	if project.Components == nil {
+		return errors.New("polaris-project.yaml doesn't record the components of the project (it was generated by an older version of polaris), so they can't be rendered again. Record each of them under components (or add components: {} if the project has none)")
+	}
+	return nil
+}
+
+// renameProjectDirectory renames the current directory to the new name of the project, when it is named after
+// the project, returning the directory the project is now in (empty when it hasn't been renamed)
+//
+func renameProjectDirectory(oldName string, newName string, report *RerenderReport) (string, error) {
+	directory, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	if filepath.Base(directory) != filepath.Base(filepath.FromSlash(oldName)) || filepath.Base(newName) != newName {
+		return "", nil
+	}
+
+	target := filepath.Join(filepath.Dir(directory), newName)
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		report.Skipped = append(report.Skipped, fmt.Sprintf("%s (%s already exists)", directory, target))
+		return "", nil
+	}
+	err = os.Rename(directory, target)
+	if err != nil {
+		return "", err
+	}
+	report.Moved = append(report.Moved, fmt.Sprintf("%s -> %s", directory, target))
+	return target, nil
+}
+
+// RenameProject renames the project within the current directory, rendering the project and all
+// of its components again under the new name, and renames the directory too when it is named after
+// the project. The component scaffolds are keyed by component name and the feature pack scaffolds
+// by FeatureKey. Returns the directory the project is now in, if it has been renamed
+//
+func RenameProject(polarisHome string, projectScaffold *config.PolarisScaffold, componentScaffolds map[string]*config.PolarisScaffold, featureScaffolds map[string]*config.PolarisScaffold, project *config.PolarisProject, newName string) (*RerenderReport, string, error) {
+	err := checkComponentsRecorded(project)
+	if err != nil {
+		return nil, "", err
+	}
+	report := &RerenderReport{}
+
+	oldProject, err := resolveProjectValues(polarisHome, project)
+	if err != nil {
+		return nil, "", err
+	}
+	newProject := *oldProject
+	newProject.Project = newName
+
+	err = rerender(projectScaffold, projectScaffold, oldProject, &newProject, ".", report)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for componentName, componentScaffold := range componentScaffolds {
+		component, err := recordedComponentValues(project, componentName)
+		if err != nil {
+			return nil, "", err
+		}
+		oldComponent, err := resolveComponentValues(polarisHome, component)
+		if err != nil {
+			return nil, "", err
+		}
+		newComponent := *oldComponent
+		newComponent.Project = newName
+
+		err = rerenderComponent(polarisHome, componentScaffold, componentScaffold, featureScaffolds, featureScaffolds, project.Components[componentName], oldComponent, &newComponent, report)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	oldName := project.Project
+	project.Project = newName
+	err = SaveLocalProject(".", project)
+	if err != nil {
+		return nil, "", err
+	}
+
+	directory, err := renameProjectDirectory(oldName, newName, report)
+	if err != nil {
+		return nil, "", err
+	}
+	return report, directory, nil
+}
+
+// RenameComponent renames a component of the project within the current directory, rendering it (and its feature
+// packs) again under the new name
+//
+func RenameComponent(polarisHome string, componentScaffold *config.PolarisScaffold, featureScaffolds map[string]*config.PolarisScaffold, project *config.PolarisProject, oldName string, newName string) (*RerenderReport, error) {
+	if _, found := project.Components[newName]; found {
+		return nil, fmt.Errorf("Component %s already exists", newName)
+	}
+
+	component, err := recordedComponentValues(project, oldName)
+	if err != nil {
+		return nil, err
+	}
+	oldComponent, err := resolveComponentValues(polarisHome, component)
+	if err != nil {
+		return nil, err
+	}
+	newComponent := *oldComponent
+	newComponent.Component = newName
+
+	report := &RerenderReport{}
+	err = rerenderComponent(polarisHome, componentScaffold, componentScaffold, featureScaffolds, featureScaffolds, project.Components[oldName], oldComponent, &newComponent, report)
+	if err != nil {
+		return nil, err
+	}
+
+	project.Components[newName] = project.Components[oldName]
+	delete(project.Components, oldName)
+	return report, SaveLocalProject(".", project)
+}
+
+// checkParameters makes sure every parameter being set is either specified by the scaffold or already recorded
+//
+func checkParameters(specParameters []config.PolarisScaffoldParameter, recorded map[string]string, parameters map[string]string) error {
+	for name := range parameters {
+		if _, found := recorded[name]; found {
+			continue
+		}
+		found := false
+		for _, parameter := range specParameters {
+			if parameter.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("Unknown parameter %s", name)
+		}
+	}
+	return nil
+}
+
+// mergeParameters returns a copy of the recorded parameters with the new values set
+//
+func mergeParameters(recorded map[string]string, parameters map[string]string) map[string]string {
+	merged := map[string]string{}
+	for name, value := range recorded {
+		merged[name] = value
+	}
+	for name, value := range parameters {
+		merged[name] = value
+	}
+	return merged
+}
+
+// SetProjectParameters changes parameters of the project within the current directory, rendering the
+// project and all of its components (which may use the project's parameters) again with the new values.
+// The scaffolds are keyed in the same way as for RenameProject
+//
+func SetProjectParameters(polarisHome string, projectScaffold *config.PolarisScaffold, componentScaffolds map[string]*config.PolarisScaffold, featureScaffolds map[string]*config.PolarisScaffold, project *config.PolarisProject, parameters map[string]string) (*RerenderReport, error) {
+	err := checkComponentsRecorded(project)
+	if err != nil {
+		return nil, err
+	}
+	err = checkParameters(projectScaffold.Spec.Parameters, project.Parameters, parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	// The old values are resolved before any secrets are replaced in the store
+	//
+	oldProject, err := resolveProjectValues(polarisHome, project)
+	if err != nil {
+		return nil, err
+	}
+	oldComponents := map[string]*config.PolarisComponent{}
+	for componentName := range componentScaffolds {
+		component, err := recordedComponentValues(project, componentName)
+		if err != nil {
+			return nil, err
+		}
+		oldComponents[componentName], err = resolveComponentValues(polarisHome, component)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The new values are rendered before any secrets among them are stored, so that a render which fails
+	// leaves the secret store as it was
+	//
+	newParameters := mergeParameters(project.Parameters, parameters)
+	err = generateSecrets(projectScaffold.Spec.Parameters, newParameters)
+	if err != nil {
+		return nil, err
+	}
+	newValues := *project
+	newValues.Parameters = newParameters
+	newProject, err := resolveProjectValues(polarisHome, &newValues)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RerenderReport{}
+	err = rerender(projectScaffold, projectScaffold, oldProject, newProject, ".", report)
+	if err != nil {
+		return nil, err
+	}
+
+	for componentName, componentScaffold := range componentScaffolds {
+		newComponent := *oldComponents[componentName]
+		newComponent.ProjectParameters = newProject.Parameters
+
+		err = rerenderComponent(polarisHome, componentScaffold, componentScaffold, featureScaffolds, featureScaffolds, project.Components[componentName], oldComponents[componentName], &newComponent, report)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	keyPrefix, err := secretKeyPrefix(project)
+	if err != nil {
+		return nil, err
+	}
+	err = storeSecrets(polarisHome, projectScaffold.Spec.Parameters, newParameters, keyPrefix, true)
+	if err != nil {
+		return nil, err
+	}
+	project.Parameters = newParameters
+	return report, SaveLocalProject(".", project)
+}
+
+// SetComponentParameters changes parameters of a component of the project within the current directory,
+// rendering it (and its feature packs) again with the new values
+//
+func SetComponentParameters(polarisHome string, componentScaffold *config.PolarisScaffold, featureScaffolds map[string]*config.PolarisScaffold, project *config.PolarisProject, componentName string, parameters map[string]string) (*RerenderReport, error) {
+	component, err := recordedComponentValues(project, componentName)
+	if err != nil {
+		return nil, err
+	}
+	err = checkParameters(componentScaffold.Spec.Parameters, component.Parameters, parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	oldComponent, err := resolveComponentValues(polarisHome, component)
+	if err != nil {
+		return nil, err
+	}
+
+	// As for the project, secrets are only stored once the new values have been rendered
+	//
+	newParameters := mergeParameters(component.Parameters, parameters)
+	err = generateSecrets(componentScaffold.Spec.Parameters, newParameters)
+	if err != nil {
+		return nil, err
+	}
+	newValues := *component
+	newValues.Parameters = newParameters
+	newComponent, err := resolveComponentValues(polarisHome, &newValues)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RerenderReport{}
+	err = rerenderComponent(polarisHome, componentScaffold, componentScaffold, featureScaffolds, featureScaffolds, project.Components[componentName], oldComponent, newComponent, report)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPrefix, err := secretKeyPrefix(project)
+	if err != nil {
+		return nil, err
+	}
+	err = storeSecrets(polarisHome, componentScaffold.Spec.Parameters, newParameters, fmt.Sprintf("%s/%s", keyPrefix, componentName), true)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded := project.Components[componentName]
+	recorded.Parameters = newParameters
+	project.Components[componentName] = recorded
+	return report, SaveLocalProject(".", project)
+}
@@ -0,0 +1,593 @@
+package scaffold
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+	"github.com/synthesis-labs/polaris-cli/src/secrets"
+)
+
+// hasEntry tells whether one of the entries of a report starts with the prefix, ignoring the ./ the
+// paths within the project start with
+//
+func hasEntry(entries []string, prefix string) bool {
+	for _, entry := range entries {
+		entry = strings.Replace(strings.TrimPrefix(filepath.ToSlash(entry), "./"), " -> ./", " -> ", 1)
+		if strings.HasPrefix(entry, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRerenderPatches(t *testing.T) {
+	root, err := ioutil.TempDir("", "polaris-rerender")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	polarisHome := filepath.Join(root, "home")
+
+	componentScaffold := newTestScaffold(t, root, "test/component",
+		[]config.PolarisScaffoldParameter{{Name: "port", Default: "8080"}},
+		map[string]string{"[[ .Component ]]/main.txt": "port [[ .Parameters.port ]]\n"})
+	componentScaffold.Spec.Patches = []config.PolarisScaffoldPatch{
+		{File: "routes.txt", Type: "insert", Marker: "# end of routes", Value: "/[[ .Component ]] -> [[ .Component ]]:[[ .Parameters.port ]]"},
+		{File: "chart/values.yaml", Type: "merge", Path: "components", Value: "[[ .Component ]]:\n  port: [[ .Parameters.port ]]"},
+	}
+
+	projectPath := filepath.Join(root, "project")
+	writeFiles(t, projectPath, map[string]string{
+		"routes.txt":        "/ -> gateway:80\n# end of routes\n",
+		"chart/values.yaml": "components:\n  gateway:\n    port: 80\n",
+	})
+	defer inDirectory(t, projectPath)()
+	project := &config.PolarisProject{
+		Project:    "orders",
+		ID:         "orders",
+		Parameters: map[string]string{},
+		Components: map[string]config.PolarisProjectComponent{},
+	}
+	err = UnpackComponent(polarisHome, componentScaffold, project, map[string]string{}, "test/component", "api", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	unpackedValues := readFile("chart/values.yaml")
+	if !strings.Contains(unpackedValues, "api:\n    port: 8080") {
+		t.Fatalf("chart/values.yaml wasn't patched:\n%s", unpackedValues)
+	}
+	noFeatures := map[string]*config.PolarisScaffold{}
+
+	// Renaming the component swaps the inserted line for the new one, but leaves what was merged (which
+	// can't be told apart from what the user has added) to be changed by hand
+	//
+	report, err := RenameComponent(polarisHome, componentScaffold, noFeatures, project, "api", "web")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := readFile("routes.txt"); got != "/ -> gateway:80\n/web -> web:8080\n# end of routes\n" {
+		t.Errorf("routes.txt = %q", got)
+	}
+	if got := readFile("chart/values.yaml"); got != unpackedValues {
+		t.Errorf("chart/values.yaml was changed, although what was merged for api is still there:\n%s", got)
+	}
+	if !hasEntry(report.Patched, "routes.txt") {
+		t.Errorf("routes.txt isn't reported as patched: %v", report.Patched)
+	}
+	if !hasEntry(report.Unpatched, "chart/values.yaml (merge patch at components") {
+		t.Errorf("chart/values.yaml isn't reported as needing changing by hand: %v", report.Unpatched)
+	}
+
+	// Once it has been changed by hand, the new values are merged in when they change again. A dry run
+	// only reports the change
+	//
+	writeFiles(t, ".", map[string]string{"chart/values.yaml": "components:\n  gateway:\n    port: 80\n"})
+	oldRoutes := readFile("routes.txt")
+	oldComponent, err := recordedComponentValues(project, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newComponent := *oldComponent
+	newComponent.Parameters = map[string]string{"port": "9090"}
+	dryRun := &RerenderReport{DryRun: true}
+	err = rerender(componentScaffold, componentScaffold, oldComponent, &newComponent, ".", dryRun)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := readFile("routes.txt"); got != oldRoutes {
+		t.Errorf("a dry run changed routes.txt to %q", got)
+	}
+	if !hasEntry(dryRun.Patched, "routes.txt") || !hasEntry(dryRun.Patched, "chart/values.yaml") {
+		t.Errorf("the dry run doesn't report both files as patched: %v", dryRun.Patched)
+	}
+
+	report, err = SetComponentParameters(polarisHome, componentScaffold, noFeatures, project, "web", map[string]string{"port": "9090"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := readFile("routes.txt"); got != "/ -> gateway:80\n/web -> web:9090\n# end of routes\n" {
+		t.Errorf("routes.txt = %q", got)
+	}
+	if got := readFile("chart/values.yaml"); !strings.Contains(got, "web:\n    port: 9090") {
+		t.Errorf("chart/values.yaml wasn't patched with the new values:\n%s", got)
+	}
+	if len(report.Unpatched) != 0 {
+		t.Errorf("unexpected patches to change by hand: %v", report.Unpatched)
+	}
+}
+
+// newTestProject unpacks a project from the scaffold into root/name, along with an api component, and
+// changes into it, returning the project as recorded and a function which changes back
+//
+func newTestProject(t *testing.T, polarisHome string, root string, name string, projectScaffold *config.PolarisScaffold, componentScaffold *config.PolarisScaffold) (*config.PolarisProject, func()) {
+	back := inDirectory(t, root)
+	err := UnpackProject(polarisHome, projectScaffold, map[string]string{}, name, false)
+	back()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	back = inDirectory(t, filepath.Join(root, name))
+	project, err := GetLocalProject("project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = UnpackComponent(polarisHome, componentScaffold, project, map[string]string{}, "test/component", "api", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return project, back
+}
+
+func TestRenameProject(t *testing.T) {
+	root, err := ioutil.TempDir("", "polaris-rename")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	polarisHome := filepath.Join(root, "home")
+
+	projectScaffold := newTestScaffold(t, root, "test/project", nil, map[string]string{
+		"[[ .Project ]]/README.md": "# [[ .Project ]]\n",
+		"chart/Chart.yaml":         "name: [[ .Project ]]\ndescription: the chart\nversion: 1\n",
+		"notes.txt":                "About [[ .Project ]]\n",
+		"static.txt":               "static\n",
+	})
+	componentScaffold := newTestScaffold(t, root, "test/component", nil, map[string]string{
+		"[[ .Component ]]/values.yaml": "project: [[ .Project ]]\n",
+	})
+	project, back := newTestProject(t, polarisHome, root, "orders", projectScaffold, componentScaffold)
+	defer back()
+
+	// One edit which can be merged, and one which conflicts with the new name
+	//
+	writeFiles(t, ".", map[string]string{
+		"chart/Chart.yaml": "name: orders\ndescription: the chart\nversion: 2\n",
+		"notes.txt":        "About the orders service\n",
+	})
+
+	report, directory, err := RenameProject(polarisHome, projectScaffold, map[string]*config.PolarisScaffold{"api": componentScaffold}, map[string]*config.PolarisScaffold{}, project, "billing")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if directory != filepath.Join(root, "billing") {
+		t.Errorf("the project is in %q, want %q", directory, filepath.Join(root, "billing"))
+	}
+	if _, err := os.Stat(filepath.Join(root, "orders")); !os.IsNotExist(err) {
+		t.Errorf("the old project directory is still there")
+	}
+
+	files := map[string]string{
+		"billing/README.md": "# billing\n",
+		"chart/Chart.yaml":  "name: billing\ndescription: the chart\nversion: 2\n",
+		"notes.txt":         "<<<<<<< local\nAbout the orders service\n=======\nAbout billing\n>>>>>>> scaffold\n",
+		"static.txt":        "static\n",
+		"api/values.yaml":   "project: billing\n",
+	}
+	for name, want := range files {
+		if got := readFile(filepath.Join(directory, name)); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(directory, "orders")); !os.IsNotExist(err) {
+		t.Errorf("the directory named after the old project is still there")
+	}
+
+	recorded, err := GetLocalProjectIn(directory, "project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recorded.Project != "billing" || recorded.ID != project.ID {
+		t.Errorf("polaris-project.yaml records project %s (id %s), want billing (id %s)", recorded.Project, recorded.ID, project.ID)
+	}
+	if _, found := recorded.Components["api"]; !found {
+		t.Errorf("polaris-project.yaml no longer records the api component")
+	}
+
+	if !hasEntry(report.Moved, "orders/README.md -> billing/README.md") {
+		t.Errorf("the README isn't reported as moved: %v", report.Moved)
+	}
+	if !hasEntry(report.Merged, "chart/Chart.yaml") || !hasEntry(report.Conflicted, "notes.txt") || !hasEntry(report.Updated, "api/values.yaml") {
+		t.Errorf("unexpected report: merged %v, conflicted %v, updated %v", report.Merged, report.Conflicted, report.Updated)
+	}
+}
+
+func TestRenameComponent(t *testing.T) {
+	root, err := ioutil.TempDir("", "polaris-rename")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	polarisHome := filepath.Join(root, "home")
+
+	projectScaffold := newTestScaffold(t, root, "test/project", nil, map[string]string{"README.md": "# [[ .Project ]]\n"})
+	componentScaffold := newTestScaffold(t, root, "test/component", nil, map[string]string{
+		"[[ .Component ]]/values.yaml":            "name: [[ .Component ]]\nimage: nginx\nreplicas: 1\n",
+		"[[ .Component ]]/templates/service.yaml": "service: [[ .Component ]]\n",
+		"images/[[ .Component ]]/Dockerfile":      "FROM scratch\n",
+	})
+	project, back := newTestProject(t, polarisHome, root, "orders", projectScaffold, componentScaffold)
+	defer back()
+	writeFiles(t, ".", map[string]string{"api/values.yaml": "name: api\nimage: nginx\nreplicas: 3\n"})
+
+	_, err = RenameComponent(polarisHome, componentScaffold, map[string]*config.PolarisScaffold{}, project, "api", "api")
+	if err == nil || !strings.Contains(err.Error(), "Component api already exists") {
+		t.Errorf("expected renaming to a component which exists to fail, got %v", err)
+	}
+
+	report, err := RenameComponent(polarisHome, componentScaffold, map[string]*config.PolarisScaffold{}, project, "api", "web")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	files := map[string]string{
+		"web/values.yaml":            "name: web\nimage: nginx\nreplicas: 3\n",
+		"web/templates/service.yaml": "service: web\n",
+		"images/web/Dockerfile":      "FROM scratch\n",
+		"README.md":                  "# orders\n",
+	}
+	for name, want := range files {
+		if got := readFile(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	for _, gone := range []string{"api", "images/api"} {
+		if _, err := os.Stat(filepath.FromSlash(gone)); !os.IsNotExist(err) {
+			t.Errorf("%s is still there", gone)
+		}
+	}
+
+	recorded, err := GetLocalProject("project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := recorded.Components["api"]; found {
+		t.Errorf("polaris-project.yaml still records the api component")
+	}
+	if recorded.Components["web"].Scaffold != "test/component" {
+		t.Errorf("polaris-project.yaml records web as %+v", recorded.Components["web"])
+	}
+	if !hasEntry(report.Merged, "web/values.yaml") || len(report.Moved) != 3 {
+		t.Errorf("unexpected report: merged %v, moved %v", report.Merged, report.Moved)
+	}
+}
+
+func TestRenameProjectDirectory(t *testing.T) {
+	tests := []struct {
+		name        string
+		directory   string
+		oldName     string
+		newName     string
+		existing    string
+		wantRenamed bool
+		wantSkipped bool
+	}{
+		{"named after the project", "orders", "orders", "billing", "", true, false},
+		{"named after the last part of the project", "orders", "team/orders", "billing", "", true, false},
+		{"not named after the project", "work", "orders", "billing", "", false, false},
+		{"new name with a /", "orders", "orders", "team/billing", "", false, false},
+		{"new directory already there", "orders", "orders", "billing", "billing", false, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "polaris-rename")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(root)
+			root, err = filepath.EvalSymlinks(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeFiles(t, root, map[string]string{filepath.Join(test.directory, "polaris-project.yaml"): "project: x\n"})
+			if test.existing != "" {
+				writeFiles(t, root, map[string]string{filepath.Join(test.existing, "keep"): "keep\n"})
+			}
+			defer inDirectory(t, filepath.Join(root, test.directory))()
+
+			report := &RerenderReport{}
+			directory, err := renameProjectDirectory(test.oldName, test.newName, report)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if test.wantRenamed {
+				want := filepath.Join(root, test.newName)
+				if directory != want || readFile(filepath.Join(want, "polaris-project.yaml")) != "project: x\n" {
+					t.Errorf("renamed to %q, want %q", directory, want)
+				}
+			} else if directory != "" {
+				t.Errorf("unexpectedly renamed to %q", directory)
+			}
+			if got := len(report.Skipped) > 0; got != test.wantSkipped {
+				t.Errorf("skipped %v", report.Skipped)
+			}
+		})
+	}
+}
+
+func TestSetProjectParameters(t *testing.T) {
+	root, err := ioutil.TempDir("", "polaris-set")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	polarisHome := filepath.Join(root, "home")
+
+	projectScaffold := newTestScaffold(t, root, "test/project",
+		[]config.PolarisScaffoldParameter{{Name: "cluster", Default: "dev"}, {Name: "region", Default: "eu-west-1"}},
+		map[string]string{
+			"clusters/[[ .Parameters.cluster ]].yaml": "name: [[ .Parameters.cluster ]]\n",
+			"config.yaml": "cluster: [[ .Parameters.cluster ]]\nregion: [[ .Parameters.region ]]\nreplicas: 1\n",
+			"region.txt":  "[[ .Parameters.region ]]\n",
+		})
+	componentScaffold := newTestScaffold(t, root, "test/component", nil, map[string]string{
+		"[[ .Component ]]/values.yaml": "cluster: [[ .ProjectParameters.cluster ]]\n",
+	})
+	project, back := newTestProject(t, polarisHome, root, "orders", projectScaffold, componentScaffold)
+	defer back()
+	writeFiles(t, ".", map[string]string{"config.yaml": "cluster: dev\nregion: eu-west-1\nreplicas: 3\n"})
+	componentScaffolds := map[string]*config.PolarisScaffold{"api": componentScaffold}
+	noFeatures := map[string]*config.PolarisScaffold{}
+
+	_, err = SetProjectParameters(polarisHome, projectScaffold, componentScaffolds, noFeatures, project, map[string]string{"zone": "a"})
+	if err == nil || !strings.Contains(err.Error(), "Unknown parameter zone") {
+		t.Errorf("expected setting an unknown parameter to fail, got %v", err)
+	}
+
+	report, err := SetProjectParameters(polarisHome, projectScaffold, componentScaffolds, noFeatures, project, map[string]string{"cluster": "prod"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	files := map[string]string{
+		"clusters/prod.yaml": "name: prod\n",
+		"config.yaml":        "cluster: prod\nregion: eu-west-1\nreplicas: 3\n",
+		"region.txt":         "eu-west-1\n",
+		"api/values.yaml":    "cluster: prod\n",
+	}
+	for name, want := range files {
+		if got := readFile(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join("clusters", "dev.yaml")); !os.IsNotExist(err) {
+		t.Errorf("clusters/dev.yaml is still there")
+	}
+
+	recorded, err := GetLocalProject("project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recorded.Parameters["cluster"] != "prod" || recorded.Parameters["region"] != "eu-west-1" {
+		t.Errorf("polaris-project.yaml records the parameters %v", recorded.Parameters)
+	}
+
+	if !hasEntry(report.Moved, "clusters/dev.yaml -> clusters/prod.yaml") || !hasEntry(report.Merged, "config.yaml") || !hasEntry(report.Updated, "api/values.yaml") {
+		t.Errorf("unexpected report: moved %v, merged %v, updated %v", report.Moved, report.Merged, report.Updated)
+	}
+	if hasEntry(report.Updated, "region.txt") {
+		t.Errorf("region.txt, which doesn't use the parameter, is reported as updated")
+	}
+}
+
+func TestSetComponentParameters(t *testing.T) {
+	root, err := ioutil.TempDir("", "polaris-set")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	polarisHome := filepath.Join(root, "home")
+
+	projectScaffold := newTestScaffold(t, root, "test/project", nil, map[string]string{"README.md": "# [[ .Project ]]\n"})
+	componentScaffold := newTestScaffold(t, root, "test/component",
+		[]config.PolarisScaffoldParameter{{Name: "port", Default: "8080"}, {Name: "password", Secret: true, Generate: "password"}},
+		map[string]string{
+			"[[ .Component ]]/values.yaml": "port: [[ .Parameters.port ]]\n",
+			"[[ .Component ]]/secret.txt":  "[[ .Parameters.password ]]\n",
+		})
+	project, back := newTestProject(t, polarisHome, root, "orders", projectScaffold, componentScaffold)
+	defer back()
+	oldPassword := readFile("api/secret.txt")
+	noFeatures := map[string]*config.PolarisScaffold{}
+
+	_, err = SetComponentParameters(polarisHome, componentScaffold, noFeatures, project, "web", map[string]string{"port": "9090"})
+	if err == nil || !strings.Contains(err.Error(), "Component web is not recorded") {
+		t.Errorf("expected setting a parameter of an unknown component to fail, got %v", err)
+	}
+
+	report, err := SetComponentParameters(polarisHome, componentScaffold, noFeatures, project, "api", map[string]string{"port": "9090", "password": "hunter2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := readFile("api/values.yaml"); got != "port: 9090\n" {
+		t.Errorf("api/values.yaml = %q", got)
+	}
+	if got := readFile("api/secret.txt"); got != "hunter2\n" || got == oldPassword {
+		t.Errorf("api/secret.txt = %q", got)
+	}
+	if len(report.Updated) != 2 {
+		t.Errorf("unexpected report: updated %v", report.Updated)
+	}
+
+	// The secret is replaced in the store, and polaris-project.yaml still only refers to it
+	//
+	recorded, err := GetLocalProject("project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parameters := recorded.Components["api"].Parameters
+	if parameters["port"] != "9090" {
+		t.Errorf("polaris-project.yaml records port %q", parameters["port"])
+	}
+	if got := readFile("polaris-project.yaml"); strings.Contains(got, "hunter2") {
+		t.Errorf("polaris-project.yaml holds the secret:\n%s", got)
+	}
+	if got, _ := secrets.Resolve(polarisHome, parameters["password"]); got != "hunter2" {
+		t.Errorf("password resolves to %q, want hunter2", got)
+	}
+}
+
+func TestRerender(t *testing.T) {
+	tests := []struct {
+		name           string
+		oldFiles       map[string]string
+		newFiles       map[string]string
+		local          map[string]string
+		dryRun         bool
+		wantFiles      map[string]string
+		wantGone       []string
+		wantCreated    []string
+		wantRemoved    []string
+		wantConflicted []string
+		wantSkipped    []string
+	}{
+		{
+			name:        "added file",
+			oldFiles:    map[string]string{"a.txt": "a [[ .Project ]]\n"},
+			newFiles:    map[string]string{"a.txt": "a [[ .Project ]]\n", "b.txt": "b [[ .Project ]]\n"},
+			wantFiles:   map[string]string{"a.txt": "a orders\n", "b.txt": "b orders\n"},
+			wantCreated: []string{"b.txt"},
+		},
+		{
+			name:        "added file already there",
+			oldFiles:    map[string]string{"a.txt": "a\n"},
+			newFiles:    map[string]string{"a.txt": "a\n", "b.txt": "b\n"},
+			local:       map[string]string{"b.txt": "mine\n"},
+			wantFiles:   map[string]string{"b.txt": "mine\n"},
+			wantSkipped: []string{"b.txt (already exists)"},
+		},
+		{
+			name:        "removed file",
+			oldFiles:    map[string]string{"a.txt": "a\n", "b/b.txt": "b\n"},
+			newFiles:    map[string]string{"a.txt": "a\n"},
+			wantGone:    []string{"b/b.txt", "b"},
+			wantRemoved: []string{"b/b.txt"},
+		},
+		{
+			name:        "removed file edited locally",
+			oldFiles:    map[string]string{"a.txt": "a\n", "b.txt": "b\n"},
+			newFiles:    map[string]string{"a.txt": "a\n"},
+			local:       map[string]string{"b.txt": "mine\n"},
+			wantFiles:   map[string]string{"b.txt": "mine\n"},
+			wantSkipped: []string{"b.txt (edited locally, no longer generated)"},
+		},
+		{
+			name:           "conflicting edit",
+			oldFiles:       map[string]string{"a.txt": "one\ntwo\nthree\n"},
+			newFiles:       map[string]string{"a.txt": "one\n2\nthree\n"},
+			local:          map[string]string{"a.txt": "one\ndeux\nthree\n"},
+			wantFiles:      map[string]string{"a.txt": "one\n<<<<<<< local\ndeux\n=======\n2\n>>>>>>> scaffold\nthree\n"},
+			wantConflicted: []string{"a.txt"},
+		},
+		{
+			name:        "file removed locally",
+			oldFiles:    map[string]string{"a.txt": "a\n"},
+			newFiles:    map[string]string{"a.txt": "A\n"},
+			local:       map[string]string{"a.txt": ""},
+			wantGone:    []string{"a.txt"},
+			wantSkipped: []string{"a.txt (removed locally)"},
+		},
+		{
+			name:        "dry run",
+			oldFiles:    map[string]string{"a.txt": "a\n", "b.txt": "b\n"},
+			newFiles:    map[string]string{"a.txt": "A\n", "c.txt": "c\n"},
+			dryRun:      true,
+			wantFiles:   map[string]string{"a.txt": "a\n", "b.txt": "b\n"},
+			wantGone:    []string{"c.txt"},
+			wantCreated: []string{"c.txt"},
+			wantRemoved: []string{"b.txt"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "polaris-rerender")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(root)
+			oldScaffold := newTestScaffold(t, root, "test/old", nil, test.oldFiles)
+			newScaffold := newTestScaffold(t, root, "test/new", nil, test.newFiles)
+			values := &config.PolarisProject{Project: "orders", Parameters: map[string]string{}}
+
+			projectPath := filepath.Join(root, "project")
+			renderedFiles, err := renderUnpack(oldScaffold, values, projectPath, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = writeUnpacked(renderedFiles, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, contents := range test.local {
+				if contents == "" {
+					os.Remove(filepath.Join(projectPath, filepath.FromSlash(name)))
+				} else {
+					writeFiles(t, projectPath, map[string]string{name: contents})
+				}
+			}
+			defer inDirectory(t, projectPath)()
+
+			report := &RerenderReport{DryRun: test.dryRun}
+			err = rerender(oldScaffold, newScaffold, values, values, ".", report)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			for name, want := range test.wantFiles {
+				if got := readFile(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			for _, name := range test.wantGone {
+				if _, err := os.Stat(filepath.FromSlash(name)); !os.IsNotExist(err) {
+					t.Errorf("%s is still there", name)
+				}
+			}
+			sections := []struct {
+				name    string
+				entries []string
+				want    []string
+			}{
+				{"created", report.Created, test.wantCreated},
+				{"removed", report.Removed, test.wantRemoved},
+				{"conflicted", report.Conflicted, test.wantConflicted},
+				{"skipped", report.Skipped, test.wantSkipped},
+			}
+			for _, section := range sections {
+				if len(section.entries) != len(section.want) {
+					t.Errorf("%s %v, want %v", section.name, section.entries, section.want)
+					continue
+				}
+				for _, want := range section.want {
+					if !hasEntry(section.entries, want) {
+						t.Errorf("%s %v, want %v", section.name, section.entries, section.want)
+					}
+				}
+			}
+		})
+	}
+}
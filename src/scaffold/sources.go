@@ -0,0 +1,108 @@
+package scaffold
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+
+	"github.com/synthesis-labs/polaris-cli/src/cluster"
+)
+
+// kubeContext caches the current kube context, which is only looked up when needed
+//
+var kubeContext struct {
+	loaded    bool
+	name      string
+	cluster   string
+	namespace string
+	err       error
+}
+
+func getKubeContext() (string, string, string, error) {
+	if !kubeContext.loaded {
+		kubeContext.name, kubeContext.cluster, kubeContext.namespace, kubeContext.err = cluster.GetCurrentContext()
+		kubeContext.loaded = true
+	}
+	return kubeContext.name, kubeContext.cluster, kubeContext.namespace, kubeContext.err
+}
+
+// gitConfig returns a value from the user's git config (empty if it isn't set)
+//
+func gitConfig(key string) (string, error) {
+	output, err := exec.Command("git", "config", "--get", key).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// lookupSource looks up the value of an external source of a parameter, being one of env:<NAME>,
+// git:<config key> (such as git:user.email), kube:context, kube:cluster or kube:namespace
+//
+func lookupSource(source string) (string, error) {
+	split := strings.SplitN(source, ":", 2)
+	if len(split) != 2 {
+		return "", fmt.Errorf("Invalid parameter source %s", source)
+	}
+
+	switch split[0] {
+	case "env":
+		return os.Getenv(split[1]), nil
+	case "git":
+		return gitConfig(split[1])
+	case "kube":
+		name, clusterName, namespace, err := getKubeContext()
+		if err != nil {
+			return "", err
+		}
+		switch split[1] {
+		case "context":
+			return name, nil
+		case "cluster":
+			return clusterName, nil
+		case "namespace":
+			return namespace, nil
+		}
+	}
+
+	return "", fmt.Errorf("Unknown parameter source %s", source)
+}
+
+// renderDefault renders a parameter's default, which is a template over the other parameters
+// and the external sources, within the limits of the scaffold's repository like any other template
+//
+func renderDefault(budget *renderBudget, name string, defaultTemplate string, values interface{}) (string, error) {
+	tmpl, err := template.
+		New(fmt.Sprintf("PolarisDefaultTemplate:%s", name)).
+		Funcs(template.FuncMap{
+			"env":       os.Getenv,
+			"gitConfig": gitConfig,
+			"kubeContext": func() (string, error) {
+				contextName, _, _, err := getKubeContext()
+				return contextName, err
+			},
+			"kubeCluster": func() (string, error) {
+				_, clusterName, _, err := getKubeContext()
+				return clusterName, err
+			},
+			"kubeNamespace": func() (string, error) {
+				_, _, namespace, err := getKubeContext()
+				return namespace, err
+			},
+		}).
+		Delims("[[", "]]").
+		Parse(defaultTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	rendered, err := budget.execute(tmpl, values, fmt.Sprintf("default of parameter %s", name))
+	if err != nil {
+		return "", err
+	}
+	return string(rendered), nil
+}
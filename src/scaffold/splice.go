@@ -0,0 +1,854 @@
+package scaffold
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// pathStep is a single step into a document, being either a map key or a list index
+//
+type pathStep struct {
+	Key     interface{}
+	Index   int
+	IsIndex bool
+}
+
+func (step pathStep) String() string {
+	if step.IsIndex {
+		return strconv.Itoa(step.Index)
+	}
+	return fmt.Sprint(step.Key)
+}
+
+// describePath names a place within a document for messages
+//
+func describePath(steps []pathStep) string {
+	if len(steps) == 0 {
+		return "the document"
+	}
+	parts := []string{}
+	for _, step := range steps {
+		parts = append(parts, step.String())
+	}
+	return strings.Join(parts, ".")
+}
+
+// documentEdit is something a patch adds to a document: an entry added to the map at Path, an element
+// inserted into the list at Path (at Index), or a value given to what is null at Path
+//
+type documentEdit struct {
+	Path  []pathStep
+	Kind  string
+	Key   interface{}
+	Index int
+	Value interface{}
+}
+
+const (
+	editEntry   = "entry"
+	editElement = "element"
+	editValue   = "value"
+)
+
+// diffDocuments works out the edits which turn the original document into the updated one. As patches
+// only add to a document, anything else (such as a value being changed) is an error
+//
+func diffDocuments(original interface{}, updated interface{}, at []pathStep) ([]documentEdit, error) {
+	if reflect.DeepEqual(original, updated) {
+		return nil, nil
+	}
+	within := func(step pathStep) []pathStep {
+		return append(append([]pathStep{}, at...), step)
+	}
+
+	switch o := original.(type) {
+	case nil:
+		return []documentEdit{{Path: at, Kind: editValue, Value: updated}}, nil
+
+	case yaml.MapSlice:
+		u, isMap := updated.(yaml.MapSlice)
+		if !isMap {
+			break
+		}
+		edits := []documentEdit{}
+		kept := 0
+		for _, item := range u {
+			found := false
+			for _, existing := range o {
+				if !reflect.DeepEqual(existing.Key, item.Key) {
+					continue
+				}
+				nested, err := diffDocuments(existing.Value, item.Value, within(pathStep{Key: item.Key}))
+				if err != nil {
+					return nil, err
+				}
+				edits = append(edits, nested...)
+				found = true
+				kept++
+				break
+			}
+			if !found {
+				edits = append(edits, documentEdit{Path: at, Kind: editEntry, Key: item.Key, Value: item.Value})
+			}
+		}
+		if kept != len(o) {
+			break
+		}
+		return edits, nil
+
+	case []interface{}:
+		u, isList := updated.([]interface{})
+		if !isList {
+			break
+		}
+		edits := []documentEdit{}
+		if len(u) == len(o) {
+			for i := range o {
+				nested, err := diffDocuments(o[i], u[i], within(pathStep{Index: i, IsIndex: true}))
+				if err != nil {
+					return nil, err
+				}
+				edits = append(edits, nested...)
+			}
+			return edits, nil
+		}
+
+		// Elements are only ever inserted, so the original elements are all still there in order. The
+		// index of each inserted element is where it ends up, as the edits are made one after another
+		//
+		j := 0
+		for i, element := range u {
+			if j < len(o) && reflect.DeepEqual(o[j], element) {
+				j++
+				continue
+			}
+			edits = append(edits, documentEdit{Path: at, Kind: editElement, Index: i, Value: element})
+		}
+		if j != len(o) {
+			break
+		}
+		return edits, nil
+	}
+
+	return nil, fmt.Errorf("%s would be changed", describePath(at))
+}
+
+// spliceDocument makes the additions which turn the original document into the updated one to the
+// text of the file itself, so that everything already in it (comments, blank lines, quoting and
+// layout) is kept. Where that isn't possible an error is returned rather than writing out the file again
+//
+func spliceDocument(targetPath string, contents []byte, original interface{}, updated interface{}) ([]byte, error) {
+	edits, err := diffDocuments(original, updated, []pathStep{})
+	if err != nil {
+		return nil, err
+	}
+
+	isJSON := strings.ToLower(filepath.Ext(targetPath)) == ".json"
+	for _, edit := range edits {
+		if isJSON {
+			contents, err = spliceJSON(contents, edit)
+		} else {
+			contents, err = spliceYAML(contents, edit)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to add to %s without writing out the whole file: %s", describePath(edit.Path), err)
+		}
+	}
+
+	// Make sure the file now reads as the updated document
+	//
+	spliced := yaml.MapSlice{}
+	err = yaml.Unmarshal(contents, &spliced)
+	if err != nil {
+		return nil, fmt.Errorf("unable to add to the file without writing it out again: %s", err)
+	}
+	want, err := marshalDocument(targetPath, updated)
+	if err != nil {
+		return nil, err
+	}
+	got, err := marshalDocument(targetPath, spliced)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(want, got) {
+		return nil, errors.New("unable to add to the file without writing it out again")
+	}
+	return contents, nil
+}
+
+// lineEnding returns the line ending used by a file
+//
+func lineEnding(contents []byte) string {
+	if bytes.Contains(contents, []byte("\r\n")) {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// yamlNode is where a value is within the lines of a YAML file. A map or list in block style takes up the
+// lines [From, To) with its keys (or the dashes of its elements) at Indent. Line is the line holding the
+// key (or dash) the value belongs to, at Owner, with the value itself starting at column Inline of it
+//
+type yamlNode struct {
+	Kind   string
+	From   int
+	To     int
+	Indent int
+	Line   int
+	Owner  int
+	Inline int
+}
+
+const (
+	yamlMap    = "map"
+	yamlList   = "list"
+	yamlNull   = "null"
+	yamlScalar = "scalar"
+	yamlOther  = "other"
+)
+
+// yamlIndent is how far a line is indented
+//
+func yamlIndent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// isYAMLContent checks whether a line holds anything besides whitespace or a comment
+//
+func isYAMLContent(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed != "" && !strings.HasPrefix(trimmed, "#")
+}
+
+// isYAMLDash checks whether text starts an element of a block list
+//
+func isYAMLDash(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// contentEnd returns the line following the last line with content within [from, to)
+//
+func contentEnd(lines []string, from int, to int) int {
+	end := from
+	for i := from; i < to; i++ {
+		if isYAMLContent(lines[i]) {
+			end = i + 1
+		}
+	}
+	return end
+}
+
+// splitYAMLKey splits the key from the start of an entry of a block map, returning the key and the
+// column (within text) its value starts at
+//
+func splitYAMLKey(text string) (string, int, bool) {
+	if text == "" {
+		return "", 0, false
+	}
+	var key string
+	var colon int
+	switch {
+	case strings.HasPrefix(text, `"`):
+		end := 1
+		for end < len(text) && text[end] != '"' {
+			if text[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(text) {
+			return "", 0, false
+		}
+		unquoted, err := strconv.Unquote(text[:end+1])
+		if err != nil {
+			return "", 0, false
+		}
+		key = unquoted
+		colon = end + 1 + len(text[end+1:]) - len(strings.TrimLeft(text[end+1:], " "))
+
+	case strings.HasPrefix(text, "'"):
+		end := 1
+		for end < len(text) && (text[end] != '\'' || (end+1 < len(text) && text[end+1] == '\'')) {
+			if text[end] == '\'' {
+				end++
+			}
+			end++
+		}
+		if end >= len(text) {
+			return "", 0, false
+		}
+		key = strings.Replace(text[1:end], "''", "'", -1)
+		colon = end + 1 + len(text[end+1:]) - len(strings.TrimLeft(text[end+1:], " "))
+
+	case strings.ContainsAny(text[:1], "?&*!|>[{%@`#"):
+		return "", 0, false
+
+	default:
+		colon = -1
+		for i := 0; i < len(text); i++ {
+			if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+				colon = i
+				break
+			}
+		}
+		if colon < 0 {
+			return "", 0, false
+		}
+		key = strings.TrimSpace(text[:colon])
+	}
+
+	if colon >= len(text) || text[colon] != ':' || (colon+1 < len(text) && text[colon+1] != ' ') {
+		return "", 0, false
+	}
+	return key, colon + 1, true
+}
+
+// yamlValue works out what the value of the key (or dash) at column owner of the given line is, its
+// inline part starting at column inline and any block part following on the lines up to end
+//
+func yamlValue(lines []string, line int, owner int, inline int, end int) yamlNode {
+	node := yamlNode{Line: line, Owner: owner, Inline: inline, From: line + 1, To: end}
+
+	value := strings.TrimSpace(lines[line][inline:])
+	if strings.HasPrefix(value, "#") {
+		value = ""
+	}
+	if comment := strings.Index(value, " #"); comment >= 0 {
+		value = strings.TrimSpace(value[:comment])
+	}
+
+	switch {
+	case value == "":
+		for i := line + 1; i < end; i++ {
+			if !isYAMLContent(lines[i]) {
+				continue
+			}
+			node.Indent = yamlIndent(lines[i])
+			if isYAMLDash(strings.TrimSpace(lines[i])) {
+				node.Kind = yamlList
+			} else {
+				node.Kind = yamlMap
+			}
+			return node
+		}
+		node.Kind = yamlNull
+	case value == "~" || value == "null" || value == "Null" || value == "NULL":
+		node.Kind = yamlNull
+	case strings.ContainsAny(value[:1], "&*![{"):
+		node.Kind = yamlOther
+	default:
+		node.Kind = yamlScalar
+	}
+	return node
+}
+
+// yamlChildEnd finds where the value of an entry at the given indentation, whose key is on line, ends
+//
+func yamlChildEnd(lines []string, line int, indent int, to int) int {
+	listAtIndent := false
+	for i := line + 1; i < to; i++ {
+		if !isYAMLContent(lines[i]) {
+			continue
+		}
+		lineIndent := yamlIndent(lines[i])
+		if lineIndent > indent {
+			continue
+		}
+
+		// A list may be at the same indentation as the key it belongs to
+		//
+		if lineIndent == indent && isYAMLDash(strings.TrimSpace(lines[i])) && (listAtIndent || contentEnd(lines, line+1, i) == line+1) {
+			listAtIndent = true
+			continue
+		}
+		return i
+	}
+	return to
+}
+
+// yamlEntry finds the value of a key of a block map
+//
+func yamlEntry(lines []string, node yamlNode, key string) (yamlNode, bool, error) {
+	for i := node.From; i < node.To; {
+		if !isYAMLContent(lines[i]) {
+			i++
+			continue
+		}
+		if yamlIndent(lines[i]) != node.Indent {
+			return yamlNode{}, false, fmt.Errorf("unexpected indentation on line %d", i+1)
+		}
+
+		entryKey, inline, ok := splitYAMLKey(lines[i][node.Indent:])
+		if !ok {
+			return yamlNode{}, false, fmt.Errorf("unable to read the key on line %d", i+1)
+		}
+		end := yamlChildEnd(lines, i, node.Indent, node.To)
+		if entryKey == key {
+			return yamlValue(lines, i, node.Indent, node.Indent+inline, end), true, nil
+		}
+		i = end
+	}
+	return yamlNode{}, false, nil
+}
+
+// yamlElements finds the lines holding the dashes of the elements of a block list
+//
+func yamlElements(lines []string, node yamlNode) []int {
+	dashes := []int{}
+	for i := node.From; i < node.To; i++ {
+		if isYAMLContent(lines[i]) && yamlIndent(lines[i]) == node.Indent && isYAMLDash(lines[i][node.Indent:]) {
+			dashes = append(dashes, i)
+		}
+	}
+	return dashes
+}
+
+// yamlElement finds the value of an element of a block list. When the element is a map (or list)
+// starting on the line of its dash, the dash is blanked out of the lines so that it reads as a block
+//
+func yamlElement(lines []string, node yamlNode, index int) (yamlNode, error) {
+	dashes := yamlElements(lines, node)
+	if index < 0 || index >= len(dashes) {
+		return yamlNode{}, fmt.Errorf("there is no element %d", index)
+	}
+	line := dashes[index]
+	end := node.To
+	if index+1 < len(dashes) {
+		end = dashes[index+1]
+	}
+
+	text := lines[line][node.Indent+1:]
+	inline := node.Indent + 1 + len(text) - len(strings.TrimLeft(text, " "))
+	rest := strings.TrimSpace(text)
+	if rest == "" || strings.HasPrefix(rest, "#") {
+		return yamlValue(lines, line, node.Indent, inline, end), nil
+	}
+
+	nested := yamlNode{From: line, To: end, Indent: inline, Line: line, Owner: node.Indent, Inline: inline}
+	if isYAMLDash(rest) {
+		nested.Kind = yamlList
+	} else if _, _, ok := splitYAMLKey(rest); ok {
+		nested.Kind = yamlMap
+	} else {
+		return yamlValue(lines, line, node.Indent, inline, end), nil
+	}
+	lines[line] = strings.Repeat(" ", inline) + lines[line][inline:]
+	return nested, nil
+}
+
+// yamlRoot finds the top of the (single) document in the lines of a YAML file
+//
+func yamlRoot(lines []string) (yamlNode, error) {
+	from := 0
+	for i, line := range lines {
+		if strings.HasPrefix(line, "---") || strings.HasPrefix(line, "...") || strings.HasPrefix(line, "%") {
+			if contentEnd(lines, 0, i) != 0 {
+				return yamlNode{}, errors.New("the file holds more than one document")
+			}
+			from = i + 1
+		}
+	}
+
+	node := yamlNode{Kind: yamlNull, From: from, To: len(lines), Line: -1}
+	for i := from; i < len(lines); i++ {
+		if !isYAMLContent(lines[i]) {
+			continue
+		}
+		text := strings.TrimSpace(lines[i])
+		node.Indent = yamlIndent(lines[i])
+		switch {
+		case isYAMLDash(text):
+			node.Kind = yamlList
+		case strings.ContainsAny(text[:1], "&*![{"):
+			node.Kind = yamlOther
+		default:
+			node.Kind = yamlMap
+		}
+		return node, nil
+	}
+	return node, nil
+}
+
+// yamlLines renders a value with yaml.v2, indenting each line
+//
+func yamlLines(value interface{}, indent int) ([]string, error) {
+	marshalled, err := yaml.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSuffix(string(marshalled), "\n"), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = strings.Repeat(" ", indent) + line
+		}
+	}
+	return lines, nil
+}
+
+// spliceYAML makes a single edit to the text of a YAML file
+//
+func spliceYAML(contents []byte, edit documentEdit) ([]byte, error) {
+	eol := lineEnding(contents)
+	lines := []string{}
+	for _, line := range splitLines(contents) {
+		lines = append(lines, strings.TrimSuffix(strings.TrimSuffix(string(line), "\n"), "\r"))
+	}
+	terminated := len(contents) == 0 || bytes.HasSuffix(contents, []byte("\n"))
+
+	// The edit is made to the original lines, while the lines being searched may have dashes blanked out
+	//
+	original := append([]string{}, lines...)
+	node, err := yamlRoot(lines)
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range edit.Path {
+		switch {
+		case step.IsIndex && node.Kind == yamlList:
+			node, err = yamlElement(lines, node, step.Index)
+		case !step.IsIndex && node.Kind == yamlMap:
+			var found bool
+			node, found, err = yamlEntry(lines, node, fmt.Sprint(step.Key))
+			if err == nil && !found {
+				err = fmt.Errorf("unable to find %s", step)
+			}
+		default:
+			err = fmt.Errorf("unable to find %s within a %s value", step, node.Kind)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var at int
+	var inserted []string
+	switch {
+	case edit.Kind == editEntry && node.Kind == yamlMap:
+		at = contentEnd(lines, node.From, node.To)
+		inserted, err = yamlLines(yaml.MapSlice{{Key: edit.Key, Value: edit.Value}}, node.Indent)
+
+	case edit.Kind == editEntry && node.Kind == yamlNull && node.Line < 0:
+		at = contentEnd(lines, node.From, node.To)
+		inserted, err = yamlLines(yaml.MapSlice{{Key: edit.Key, Value: edit.Value}}, 0)
+
+	case edit.Kind == editElement && node.Kind == yamlList:
+		dashes := yamlElements(lines, node)
+		if edit.Index < len(dashes) {
+			at = dashes[edit.Index]
+		} else {
+			at = contentEnd(lines, node.From, node.To)
+		}
+		inserted, err = yamlLines([]interface{}{edit.Value}, node.Indent)
+
+	case edit.Kind == editValue && node.Kind == yamlNull && node.Line >= 0:
+		// A scalar (or empty map or list) replaces the null, otherwise the value follows as a block
+		//
+		at = node.Line + 1
+		keyPart := strings.TrimRight(original[node.Line][:node.Inline], " ")
+		comment := ""
+		if hash := strings.Index(original[node.Line][node.Inline:], "#"); hash >= 0 {
+			comment = " " + original[node.Line][node.Inline+hash:]
+		}
+		_, isMap := edit.Value.(yaml.MapSlice)
+		_, isList := edit.Value.([]interface{})
+		inserted, err = yamlLines(edit.Value, node.Owner)
+		if err == nil && ((!isMap && !isList) || strings.HasPrefix(inserted[0], "{") || strings.HasPrefix(inserted[0], "[")) {
+			original[node.Line] = keyPart + " " + strings.TrimLeft(inserted[0], " ") + comment
+			inserted = inserted[1:]
+		} else if err == nil {
+			original[node.Line] = keyPart + comment
+			inserted, err = yamlLines(edit.Value, node.Owner+2)
+		}
+
+	case edit.Kind == editValue && node.Kind == yamlNull:
+		at = contentEnd(lines, node.From, node.To)
+		inserted, err = yamlLines(edit.Value, 0)
+
+	default:
+		return nil, fmt.Errorf("unable to add to a %s value", node.Kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	spliced := append(append(append([]string{}, original[:at]...), inserted...), original[at:]...)
+	result := strings.Join(spliced, eol)
+	if terminated && len(spliced) > 0 {
+		result += eol
+	}
+	return []byte(result), nil
+}
+
+// jsonValue is where a value is within the text of a JSON file: the bytes [Start, End), and for an
+// object or array where its members (and their keys) start
+//
+type jsonValue struct {
+	Start     int
+	End       int
+	Kind      byte
+	Keys      []string
+	KeyStarts []int
+	Values    []*jsonValue
+}
+
+// skipJSONSpace skips any whitespace
+//
+func skipJSONSpace(data []byte, pos int) int {
+	for pos < len(data) && strings.IndexByte(" \t\r\n", data[pos]) >= 0 {
+		pos++
+	}
+	return pos
+}
+
+// scanJSONString finds the end of the string starting at pos
+//
+func scanJSONString(data []byte, pos int) (int, error) {
+	for i := pos + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, nil
+		}
+	}
+	return 0, errors.New("unterminated string")
+}
+
+// scanJSON finds where the value starting at (or after whitespace from) pos is
+//
+func scanJSON(data []byte, pos int) (*jsonValue, error) {
+	pos = skipJSONSpace(data, pos)
+	if pos >= len(data) {
+		return nil, errors.New("unexpected end of file")
+	}
+	value := &jsonValue{Start: pos, Kind: data[pos]}
+
+	switch data[pos] {
+	case '{', '[':
+		closing := byte('}')
+		if data[pos] == '[' {
+			closing = ']'
+		}
+		pos = skipJSONSpace(data, pos+1)
+		if pos < len(data) && data[pos] == closing {
+			value.End = pos + 1
+			return value, nil
+		}
+		for {
+			if value.Kind == '{' {
+				pos = skipJSONSpace(data, pos)
+				if pos >= len(data) || data[pos] != '"' {
+					return nil, fmt.Errorf("expected a key at offset %d", pos)
+				}
+				end, err := scanJSONString(data, pos)
+				if err != nil {
+					return nil, err
+				}
+				var key string
+				err = json.Unmarshal(data[pos:end], &key)
+				if err != nil {
+					return nil, err
+				}
+				value.Keys = append(value.Keys, key)
+				value.KeyStarts = append(value.KeyStarts, pos)
+				pos = skipJSONSpace(data, end)
+				if pos >= len(data) || data[pos] != ':' {
+					return nil, fmt.Errorf("expected : at offset %d", pos)
+				}
+				pos++
+			}
+
+			member, err := scanJSON(data, pos)
+			if err != nil {
+				return nil, err
+			}
+			if value.Kind == '[' {
+				value.KeyStarts = append(value.KeyStarts, member.Start)
+			}
+			value.Values = append(value.Values, member)
+			pos = skipJSONSpace(data, member.End)
+			if pos < len(data) && data[pos] == ',' {
+				pos++
+				continue
+			}
+			if pos < len(data) && data[pos] == closing {
+				value.End = pos + 1
+				return value, nil
+			}
+			return nil, fmt.Errorf("expected , or %c at offset %d", closing, pos)
+		}
+
+	case '"':
+		end, err := scanJSONString(data, pos)
+		if err != nil {
+			return nil, err
+		}
+		value.End = end
+		return value, nil
+	}
+
+	end := pos
+	for end < len(data) && strings.IndexByte(",]} \t\r\n", data[end]) < 0 {
+		end++
+	}
+	value.End = end
+	return value, nil
+}
+
+// jsonLineIndent returns the indentation of the line holding the given offset
+//
+func jsonLineIndent(data []byte, pos int) string {
+	start := bytes.LastIndexByte(data[:pos], '\n') + 1
+	end := start
+	for end < len(data) && (data[end] == ' ' || data[end] == '\t') {
+		end++
+	}
+	return string(data[start:end])
+}
+
+// onSameLine checks whether there is no line break between two offsets
+//
+func onSameLine(data []byte, from int, to int) bool {
+	return bytes.IndexByte(data[from:to], '\n') < 0
+}
+
+// jsonIndentUnit works out how far each level of the file is indented (two spaces if it can't be told)
+//
+func jsonIndentUnit(data []byte, root *jsonValue) string {
+	if len(root.KeyStarts) > 0 && !onSameLine(data, root.Start, root.KeyStarts[0]) {
+		outer := jsonLineIndent(data, root.Start)
+		inner := jsonLineIndent(data, root.KeyStarts[0])
+		if strings.HasPrefix(inner, outer) && len(inner) > len(outer) {
+			return inner[len(outer):]
+		}
+	}
+	return "  "
+}
+
+// renderJSON renders a value for the file, either on a single line or indented as a block
+//
+func renderJSON(value interface{}, prefix string, unit string, block bool) (string, error) {
+	compact, err := marshalJSON(value)
+	if err != nil {
+		return "", err
+	}
+	if !block {
+		return string(compact), nil
+	}
+	var buff bytes.Buffer
+	err = json.Indent(&buff, compact, prefix, unit)
+	if err != nil {
+		return "", err
+	}
+	return buff.String(), nil
+}
+
+// spliceJSON makes a single edit to the text of a JSON file
+//
+func spliceJSON(contents []byte, edit documentEdit) ([]byte, error) {
+	eol := lineEnding(contents)
+	root, err := scanJSON(contents, 0)
+	if err != nil {
+		return nil, err
+	}
+	unit := jsonIndentUnit(contents, root)
+
+	node := root
+	for _, step := range edit.Path {
+		var next *jsonValue
+		switch {
+		case step.IsIndex && node.Kind == '[':
+			if step.Index >= 0 && step.Index < len(node.Values) {
+				next = node.Values[step.Index]
+			}
+		case !step.IsIndex && node.Kind == '{':
+			for i, key := range node.Keys {
+				if key == fmt.Sprint(step.Key) {
+					next = node.Values[i]
+				}
+			}
+		}
+		if next == nil {
+			return nil, fmt.Errorf("unable to find %s", step)
+		}
+		node = next
+	}
+
+	// What is added goes on its own line (indented like its neighbours) unless the object or array
+	// is all on one line
+	//
+	member := func(value interface{}, indent string, block bool) (string, error) {
+		rendered, err := renderJSON(value, indent, unit, block)
+		if err != nil || edit.Kind != editEntry {
+			return rendered, err
+		}
+		key, err := json.Marshal(fmt.Sprint(edit.Key))
+		return fmt.Sprintf("%s: %s", key, rendered), err
+	}
+
+	start, end := node.End, node.End
+	var text string
+	switch {
+	case edit.Kind == editValue && string(contents[node.Start:node.End]) == "null":
+		start, end = node.Start, node.End
+		text, err = renderJSON(edit.Value, jsonLineIndent(contents, node.Start), unit, true)
+
+	case (edit.Kind == editEntry && node.Kind == '{') || (edit.Kind == editElement && node.Kind == '['):
+		index := len(node.Values)
+		if edit.Kind == editElement {
+			index = edit.Index
+		}
+		switch {
+		case len(node.Values) == 0:
+			indent := jsonLineIndent(contents, node.Start)
+			start, end = node.Start+1, node.End-1
+			text, err = member(edit.Value, indent+unit, true)
+			text = eol + indent + unit + text + eol + indent
+
+		case index < len(node.Values):
+			start = node.KeyStarts[index]
+			end = start
+			block := !onSameLine(contents, node.Start, start)
+			indent := jsonLineIndent(contents, start)
+			text, err = member(edit.Value, indent, block)
+			if block {
+				text = text + "," + eol + indent
+			} else {
+				text = text + ", "
+			}
+
+		default:
+			last := len(node.Values) - 1
+			start = node.Values[last].End
+			end = start
+			block := !onSameLine(contents, node.Start, node.KeyStarts[last])
+			indent := jsonLineIndent(contents, node.KeyStarts[last])
+			text, err = member(edit.Value, indent, block)
+			if block {
+				text = "," + eol + indent + text
+			} else {
+				text = ", " + text
+			}
+		}
+
+	default:
+		return nil, errors.New("unable to add to a value which is not an object or array")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var buff bytes.Buffer
+	buff.Write(contents[:start])
+	buff.WriteString(text)
+	buff.Write(contents[end:])
+	return buff.Bytes(), nil
+}
@@ -0,0 +1,113 @@
+package scaffold
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+)
+
+// partialsDirectory is the directory (at the root of a repository or within a scaffold) holding
+// named templates shared by the scaffold templates
+//
+const partialsDirectory = "polaris-partials"
+
+// partial is a file of shared templates
+//
+type partial struct {
+	Name     string
+	Contents string
+}
+
+// readPartialsFrom reads every partial file within a partials directory (if it exists)
+//
+func readPartialsFrom(directory string) ([]partial, error) {
+	partials := []partial{}
+	if _, err := os.Stat(directory); os.IsNotExist(err) {
+		return partials, nil
+	}
+
+	err := filepath.Walk(directory, func(filename string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		err = checkSourcePath(directory, filename)
+		if err != nil {
+			return err
+		}
+		contents, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(directory, filename)
+		if err != nil {
+			return err
+		}
+
+		partials = append(partials, partial{Name: filepath.ToSlash(name), Contents: string(contents)})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(partials, func(i, j int) bool { return partials[i].Name < partials[j].Name })
+	return partials, nil
+}
+
+// readPartials reads the repository level partials followed by the scaffold level ones, so
+// that a scaffold's definitions override the repository's definitions of the same name
+//
+func readPartials(scaffold *config.PolarisScaffold) ([]partial, error) {
+	partials := []partial{}
+	if scaffold.RepositoryPath != "" {
+		repositoryPartials, err := readPartialsFrom(filepath.Join(scaffold.RepositoryPath, partialsDirectory))
+		if err != nil {
+			return nil, err
+		}
+		partials = append(partials, repositoryPartials...)
+	}
+
+	scaffoldPartials, err := readPartialsFrom(filepath.Join(scaffold.LocalPath, partialsDirectory))
+	if err != nil {
+		return nil, err
+	}
+	return append(partials, scaffoldPartials...), nil
+}
+
+// newScaffoldTemplate parses a scaffold template along with the partials it may use through
+// template or include
+//
+func newScaffoldTemplate(name string, contents string, partials []partial) (*template.Template, error) {
+	tmpl := template.New(name).Delims("[[", "]]")
+	tmpl.Funcs(template.FuncMap{
+		"include": func(name string, data interface{}) (string, error) {
+			var buff bytes.Buffer
+			err := tmpl.ExecuteTemplate(&buff, name, data)
+			return buff.String(), err
+		},
+		"indent": func(spaces int, s string) string {
+			pad := strings.Repeat(" ", spaces)
+			return pad + strings.Replace(s, "\n", "\n"+pad, -1)
+		},
+		"nindent": func(spaces int, s string) string {
+			pad := strings.Repeat(" ", spaces)
+			return "\n" + pad + strings.Replace(s, "\n", "\n"+pad, -1)
+		},
+	})
+
+	for _, p := range partials {
+		_, err := tmpl.New(p.Name).Parse(p.Contents)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tmpl.Parse(contents)
+}
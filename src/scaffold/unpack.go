@@ -1,7 +1,6 @@
 package scaffold
 
 import (
-	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -16,14 +15,39 @@ import (
 	yaml "gopkg.in/yaml.v2"
 )
 
-// unpackScaffold low level unpacking of a template from a repo to a local path
+// renderedFile is a single directory or file rendered from a scaffold. Key identifies the
+// rendering of the source file (which is rendered more than once when iterating over a list).
+// A binary file has no Contents, as it is copied from SourcePath as it is
 //
-func unpackScaffold(polarisType string, scaffold *config.PolarisScaffold, scaffoldValues interface{}, localPath string, overwrite bool) error {
+type renderedFile struct {
+	Key        string
+	SourcePath string
+	TargetPath string
+	IsDir      bool
+	Binary     bool
+	Contents   []byte
+}
+
+// renderScaffold renders the paths and contents of every file within a scaffold, handing each
+// one to onRendered (in walk order, so directories come before their contents)
+//
+func renderScaffold(scaffold *config.PolarisScaffold, scaffoldValues interface{}, localPath string, onRendered func(rendered renderedFile) error) error {
 	// Clean paths
 	//
 	localPath = path.Clean(localPath)
 
-	err := filepath.Walk(fmt.Sprintf("%s/", scaffold.LocalPath), func(sourcePath string, info os.FileInfo, err error) error {
+	// Shared templates available to every file
+	//
+	partials, err := readPartials(scaffold)
+	if err != nil {
+		return err
+	}
+
+	// Everything rendered counts against the limits of the scaffold's repository
+	//
+	budget := newRenderBudget(scaffold)
+
+	return filepath.Walk(fmt.Sprintf("%s/", scaffold.LocalPath), func(sourcePath string, info os.FileInfo, err error) error {
 
 		if err != nil {
 			fmt.Println(err)
@@ -32,97 +56,153 @@ func unpackScaffold(polarisType string, scaffold *config.PolarisScaffold, scaffo
 
 		// Source files to ignore
 		//
-		if info.Name() == "polaris-project.yaml" || info.Name() == "polaris-component.yaml" {
+		if info.Name() == "polaris-project.yaml" || info.Name() == "polaris-component.yaml" || info.Name() == "polaris-feature.yaml" {
 			return nil
 		}
-
-		// filename -> file from the scaffold
-		// targetPath -> file to be written (in the target)
-
-		targetPath := fmt.Sprintf("%s%s", localPath, strings.Replace(sourcePath, fmt.Sprintf("%s", scaffold.LocalPath), "", 1))
-
-		if options.IsVerbose() {
-			fmt.Println("scaffold.LocalPath", scaffold.LocalPath)
-			fmt.Println("--------------------")
-			fmt.Println("sourcePath", sourcePath)
-			fmt.Println("targetPath", targetPath)
-			fmt.Println("--------------------")
+		if info.IsDir() && info.Name() == partialsDirectory {
+			return filepath.SkipDir
 		}
 
-		// targetPath could be a templated name, so we must render it
+		// Files are keyed by their path within the scaffold, which is the same wherever the scaffold is
 		//
-		targetPathTemplate, err := template.
-			New("PolarisFilenameTemplate").
-			Funcs(template.FuncMap{}).
-			Delims("[[", "]]").
-			Parse(string(targetPath))
+		key, err := filepath.Rel(scaffold.LocalPath, sourcePath)
 		if err != nil {
-			fmt.Println("Error during template parsing", targetPath)
 			return err
 		}
-		var targetPathBuff bytes.Buffer
+		key = filepath.ToSlash(key)
 
-		err = targetPathTemplate.Execute(&targetPathBuff, scaffoldValues)
+		// Files (or directories) iterating over a list are rendered once per element
+		//
+		iteration, err := findIteration(scaffold, sourcePath)
 		if err != nil {
-			return fmt.Errorf("Error during filename template generation: %s", err)
+			return err
+		}
+		if iteration == nil {
+			return renderFile(scaffold, partials, budget, sourcePath, info, key, scaffoldValues, localPath, onRendered)
 		}
 
-		// Set the name to whatever the template rendered
+		// Each rendering is keyed by its element rather than its position, so that a file stays with its
+		// element when others are added to or removed from the list
 		//
-		targetPath = targetPathBuff.String()
-
-		if info.IsDir() {
-			err := os.MkdirAll(targetPath, os.ModePerm)
+		for index, item := range iterationItems(iteration, scaffoldValues) {
+			key := fmt.Sprintf("%s#%s", key, item)
+			err := renderFile(scaffold, partials, budget, sourcePath, info, key, withIteration(scaffoldValues, item, index), localPath, onRendered)
 			if err != nil {
 				return err
 			}
-			if options.IsVerbose() {
-				fmt.Println("Created directory", targetPath)
-			}
-		} else {
+		}
+		return nil
+	})
+}
 
-			sourceContents, err := ioutil.ReadFile(sourcePath)
-			if err != nil {
-				return err
-			}
+// renderFile renders the path and contents of a single file within a scaffold
+//
+func renderFile(scaffold *config.PolarisScaffold, partials []partial, budget *renderBudget, sourcePath string, info os.FileInfo, key string, scaffoldValues interface{}, localPath string, onRendered func(rendered renderedFile) error) error {
+	// filename -> file from the scaffold
+	// targetPath -> file to be written (in the target)
 
-			var buff bytes.Buffer
-			if !shouldExcludeFile(sourcePath) {
-				tmpl, err := template.
-					New(fmt.Sprintf("PolarisScaffoldTemplate:%s", sourcePath)).
-					Funcs(template.FuncMap{}).
-					Delims("[[", "]]").
-					Parse(string(sourceContents))
-				if err != nil {
-					fmt.Println("Error during template parsing", localPath)
-					return err
-				}
-
-				err = tmpl.Execute(&buff, scaffoldValues)
-				if err != nil {
-					return fmt.Errorf("Error during template generation: %s", err)
-				}
-			} else {
-				buff.Write(sourceContents)
-			}
+	relativePath := strings.Replace(sourcePath, fmt.Sprintf("%s", scaffold.LocalPath), "", 1)
+	targetPath := fmt.Sprintf("%s%s", localPath, relativePath)
+	scaffoldPath := strings.TrimPrefix(relativePath, "/")
 
-			if _, err := os.Stat(targetPath); !os.IsNotExist(err) && !overwrite {
-				return fmt.Errorf("%s already exists", localPath)
-			}
-			err = ioutil.WriteFile(targetPath, buff.Bytes(), 0644)
-			if err != nil {
-				return err
-			}
-			if options.IsVerbose() {
-				fmt.Println("Wrote file", sourcePath, targetPath)
-			}
+	if options.IsVerbose() {
+		fmt.Println("scaffold.LocalPath", scaffold.LocalPath)
+		fmt.Println("--------------------")
+		fmt.Println("sourcePath", sourcePath)
+		fmt.Println("targetPath", targetPath)
+		fmt.Println("--------------------")
+	}
+
+	// targetPath could be a templated name, so we must render it (only the part within the scaffold)
+	//
+	targetPathTemplate, err := template.
+		New("PolarisFilenameTemplate").
+		Funcs(template.FuncMap{}).
+		Delims("[[", "]]").
+		Parse(string(relativePath))
+	if err != nil {
+		fmt.Println("Error during template parsing", targetPath)
+		return err
+	}
+	renderedPath, err := budget.execute(targetPathTemplate, scaffoldValues, scaffoldPath)
+	if err != nil {
+		return fmt.Errorf("Error during filename template generation: %s", err)
+	}
+
+	// Set the name to whatever the template rendered, as long as it stays within the local path
+	//
+	targetPath = fmt.Sprintf("%s%s", localPath, renderedPath)
+	err = checkTargetPath(localPath, targetPath)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		err = budget.addFile(scaffoldPath, 0)
+		if err != nil {
+			return err
 		}
+		return onRendered(renderedFile{Key: key, SourcePath: sourcePath, TargetPath: targetPath, IsDir: true})
+	}
 
-		return nil
-	})
+	// The source is sniffed, read or copied to the target below, all of which follow a symbolic link
+	//
+	err = checkSourcePath(scaffold.LocalPath, sourcePath)
+	if err != nil {
+		return err
+	}
+
+	// Binary files (and excluded ones) are not templates, and are streamed to the target later
+	// rather than read into memory
+	//
+	binary, err := isBinaryFile(sourcePath)
+	if err != nil {
+		return err
+	}
+	if binary || shouldExcludeFile(sourcePath) {
+		err = budget.addFile(scaffoldPath, info.Size())
+		if err != nil {
+			return err
+		}
+		return onRendered(renderedFile{Key: key, SourcePath: sourcePath, TargetPath: targetPath, Binary: true})
+	}
+
+	sourceContents, err := ioutil.ReadFile(sourcePath)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := newScaffoldTemplate(fmt.Sprintf("PolarisScaffoldTemplate:%s", sourcePath), string(sourceContents), partials)
+	if err != nil {
+		fmt.Println("Error during template parsing", localPath)
+		return err
+	}
+
+	contents, err := budget.execute(tmpl, scaffoldValues, scaffoldPath)
+	if err != nil {
+		return fmt.Errorf("Error during template generation: %s", err)
+	}
+	err = budget.addFile(scaffoldPath, int64(len(contents)))
+	if err != nil {
+		return err
+	}
 
-	// Any errors from templating or walking
+	return onRendered(renderedFile{Key: key, SourcePath: sourcePath, TargetPath: targetPath, Contents: contents})
+}
+
+// unpackScaffold low level unpacking of a template from a repo to a local path. The templates are
+// rendered with scaffoldValues while recordedValues are written to polaris-%s.yaml
+//
+func unpackScaffold(polarisType string, scaffold *config.PolarisScaffold, scaffoldValues interface{}, recordedValues interface{}, localPath string, overwrite bool) error {
+	// Clean paths
 	//
+	localPath = path.Clean(localPath)
+
+	renderedFiles, err := renderUnpack(scaffold, scaffoldValues, localPath, overwrite)
+	if err != nil {
+		return err
+	}
+	err = writeUnpacked(renderedFiles, nil)
 	if err != nil {
 		return err
 	}
@@ -130,7 +210,7 @@ func unpackScaffold(polarisType string, scaffold *config.PolarisScaffold, scaffo
 	// Write the values to the base/polaris.yaml if the polaris-type is specified
 	//
 	if polarisType != "" {
-		projectMarshalled, err := yaml.Marshal(scaffoldValues)
+		projectMarshalled, err := yaml.Marshal(recordedValues)
 		if err != nil {
 			return err
 		}
@@ -140,11 +220,77 @@ func unpackScaffold(polarisType string, scaffold *config.PolarisScaffold, scaffo
 	return err
 }
 
+// renderUnpack renders every file of a scaffold to be unpacked into the local path. Everything is
+// rendered before anything is written, so that a scaffold which can't be rendered (or renders a
+// path it shouldn't) leaves nothing behind
+//
+func renderUnpack(scaffold *config.PolarisScaffold, scaffoldValues interface{}, localPath string, overwrite bool) ([]renderedFile, error) {
+	renderedFiles := []renderedFile{}
+	err := renderScaffold(scaffold, scaffoldValues, localPath, func(rendered renderedFile) error {
+		renderedFiles = append(renderedFiles, rendered)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !overwrite {
+		for _, rendered := range renderedFiles {
+			if _, err := os.Stat(rendered.TargetPath); !rendered.IsDir && !os.IsNotExist(err) {
+				return nil, fmt.Errorf("%s already exists", rendered.TargetPath)
+			}
+		}
+	}
+	return renderedFiles, nil
+}
+
+// writeUnpacked writes the rendered files of a scaffold, followed by the files its patches change.
+// A rendered file which is also patched is written with its patched contents
+//
+func writeUnpacked(renderedFiles []renderedFile, patched map[string][]byte) error {
+	remaining := map[string][]byte{}
+	for targetPath, contents := range patched {
+		remaining[filepath.Clean(targetPath)] = contents
+	}
+
+	for _, rendered := range renderedFiles {
+		if rendered.IsDir {
+			err := os.MkdirAll(rendered.TargetPath, os.ModePerm)
+			if err != nil {
+				return err
+			}
+			if options.IsVerbose() {
+				fmt.Println("Created directory", rendered.TargetPath)
+			}
+			continue
+		}
+
+		if contents, found := remaining[filepath.Clean(rendered.TargetPath)]; found && !rendered.Binary {
+			rendered.Contents = contents
+			delete(remaining, filepath.Clean(rendered.TargetPath))
+		}
+		err := writeRenderedFile(rendered)
+		if err != nil {
+			return err
+		}
+		if options.IsVerbose() {
+			fmt.Println("Wrote file", rendered.SourcePath, rendered.TargetPath)
+		}
+	}
+
+	return writePatches(remaining)
+}
+
 // GetLocalProject scans the local directory for a polaris-%s.yaml (project or whatever) and returns it
 //
 func GetLocalProject(polarisType string) (*config.PolarisProject, error) {
+	return GetLocalProjectIn(".", polarisType)
+}
+
+// GetLocalProjectIn scans the given directory for a polaris-%s.yaml (project or whatever) and returns it
+//
+func GetLocalProjectIn(directory string, polarisType string) (*config.PolarisProject, error) {
 
-	projectData, err := ioutil.ReadFile(fmt.Sprintf("./polaris-%s.yaml", polarisType))
+	projectData, err := ioutil.ReadFile(filepath.Join(directory, fmt.Sprintf("polaris-%s.yaml", polarisType)))
 	if err != nil {
 		return nil, err
 	}
@@ -158,9 +304,11 @@ func GetLocalProject(polarisType string) (*config.PolarisProject, error) {
 	return &project, nil
 }
 
-// UnpackProject unpacks an Application scaffold into the local path
+// newProjectValues sets up the project object used by the templates from the scaffold defaults
+// and the parameters provided, along with the specification of the parameters as resolved (see
+// resolveParameters)
 //
-func UnpackProject(scaffold *config.PolarisScaffold, parameters map[string]string, localPath string, overwrite bool) error {
+func newProjectValues(scaffold *config.PolarisScaffold, parameters map[string]string, localPath string) (*config.PolarisProject, []config.PolarisScaffoldParameter, error) {
 	// Clean paths
 	//
 	localName := path.Clean(localPath)
@@ -168,39 +316,79 @@ func UnpackProject(scaffold *config.PolarisScaffold, parameters map[string]strin
 	// Setup the project object for use by the template later
 	//
 	project := config.PolarisProject{
-		Project:    localName,
-		Parameters: map[string]string{},
-		Scaffold:   scaffold.Name,
+		Project:        localName,
+		Parameters:     map[string]string{},
+		Scaffold:       scaffold.Name,
+		ScaffoldCommit: scaffold.Commit,
+		Components:     map[string]config.PolarisProjectComponent{},
 	}
-
-	// Populate all the scaffold default parameter values first
-	//
-	for _, parameter := range scaffold.Spec.Parameters {
-		project.Parameters[parameter.Name] = parameter.Default
+	_, err := secretKeyPrefix(&project)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Overwrite them with the ones provided
+	// Resolve the parameters provided, falling back to the scaffold's sources and defaults
 	//
-	for paramKey, paramValue := range parameters {
-		project.Parameters[paramKey] = paramValue
+	resolved, specParameters, err := resolveParameters(scaffold.Spec.Parameters, parameters, defaultValues{Project: localName}, newRenderBudget(scaffold))
+	if err != nil {
+		return nil, nil, err
 	}
+	project.Parameters = resolved
 
 	// Print them
 	//
 	if options.IsVerbose() {
 		for paramKey, paramValue := range project.Parameters {
-			fmt.Println("Project parameter", paramKey, paramValue)
+			fmt.Println("Project parameter", paramKey, displayValue(specParameters, paramKey, paramValue))
 		}
 	}
 
-	err := unpackScaffold("project", scaffold, &project, localPath, overwrite)
-	return err
+	return &project, specParameters, nil
+}
 
+// SaveLocalProject writes the polaris-project.yaml into the given directory
+//
+func SaveLocalProject(directory string, project *config.PolarisProject) error {
+	projectMarshalled, err := yaml.Marshal(project)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(directory, "polaris-project.yaml"), projectMarshalled, 0644)
 }
 
-// UnpackComponent unpacks a Component scaffold into the local path
+// UnpackProject unpacks an Application scaffold into the local path
+//
+func UnpackProject(polarisHome string, scaffold *config.PolarisScaffold, parameters map[string]string, localPath string, overwrite bool) error {
+	project, specParameters, err := newProjectValues(scaffold, parameters, localPath)
+	if err != nil {
+		return err
+	}
+	resolved, err := resolveProjectValues(polarisHome, project)
+	if err != nil {
+		return err
+	}
+
+	err = unpackScaffold("", scaffold, resolved, nil, localPath, overwrite)
+	if err != nil {
+		return err
+	}
+
+	// Secrets are only recorded as references (and resolved again whenever the project is rendered). They
+	// are stored once the files are written, so that a project which can't be unpacked leaves none behind
+	//
+	err = storeSecrets(polarisHome, specParameters, project.Parameters, project.ID, false)
+	if err != nil {
+		return err
+	}
+	return SaveLocalProject(path.Clean(localPath), project)
+
+}
+
+// newComponentValues sets up the component object used by the templates from the scaffold defaults,
+// the project and the parameters provided, along with the specification of the parameters as resolved
+// (see resolveParameters)
 //
-func UnpackComponent(componentScaffold *config.PolarisScaffold, project *config.PolarisProject, parameters map[string]string, componentName string, localPath string, overwrite bool) error {
+func newComponentValues(polarisHome string, componentScaffold *config.PolarisScaffold, project *config.PolarisProject, parameters map[string]string, componentName string, localPath string) (*config.PolarisComponent, []config.PolarisScaffoldParameter, error) {
 	// Clean paths
 	//
 	localName := path.Clean(localPath)
@@ -216,34 +404,83 @@ func UnpackComponent(componentScaffold *config.PolarisScaffold, project *config.
 		ComponentScaffold: componentName,
 	}
 
-	// Populate all the scaffold default parameter values first
+	// Resolve the parameters provided on the command line, falling back to the scaffold's sources and defaults
+	// (which see the values of the project's secrets rather than their references)
 	//
-	for _, parameter := range componentScaffold.Spec.Parameters {
-		if options.IsVerbose() {
-			fmt.Println("Got component spec param", parameter.Name)
-		}
-		component.Parameters[parameter.Name] = parameter.Default
+	values, err := componentDefaultValues(polarisHome, project, localName)
+	if err != nil {
+		return nil, nil, err
 	}
-
-	// Overwrite them with the ones provided on the command line
-	//
-	for paramKey, paramValue := range parameters {
-		if options.IsVerbose() {
-			fmt.Println("Got component param", paramKey, "->", paramValue)
-		}
-		component.Parameters[paramKey] = paramValue
+	resolved, specParameters, err := resolveParameters(componentScaffold.Spec.Parameters, parameters, values, newRenderBudget(componentScaffold))
+	if err != nil {
+		return nil, nil, err
 	}
+	component.Parameters = resolved
 
 	// Print them
 	//
 	if options.IsVerbose() {
 		for paramKey, paramValue := range component.Parameters {
-			fmt.Println("Component parameter", paramKey, paramValue)
+			fmt.Println("Component parameter", paramKey, displayValue(specParameters, paramKey, paramValue))
 		}
 	}
 
-	err := unpackScaffold("", componentScaffold, &component, ".", overwrite)
-	return err
+	return &component, specParameters, nil
+}
+
+// UnpackComponent unpacks a Component scaffold into the local path
+//
+func UnpackComponent(polarisHome string, componentScaffold *config.PolarisScaffold, project *config.PolarisProject, parameters map[string]string, componentName string, localPath string, overwrite bool) error {
+	component, specParameters, err := newComponentValues(polarisHome, componentScaffold, project, parameters, componentName, localPath)
+	if err != nil {
+		return err
+	}
+
+	resolved, err := resolveComponentValues(polarisHome, component)
+	if err != nil {
+		return err
+	}
+
+	// Patches to the files of the project are worked out against what they will hold once the component
+	// is unpacked, before anything is written, so that one which can't be applied stops the component
+	// from being unpacked
+	//
+	renderedFiles, err := renderUnpack(componentScaffold, resolved, ".", overwrite)
+	if err != nil {
+		return err
+	}
+	patched, err := planPatches(componentScaffold, resolved, ".", renderedFiles)
+	if err != nil {
+		return err
+	}
+	err = writeUnpacked(renderedFiles, patched)
+	if err != nil {
+		return err
+	}
+
+	// Secrets are only recorded as references (and resolved again whenever the component is rendered). They
+	// are stored once the files are written, so that a component which can't be unpacked leaves none behind
+	//
+	keyPrefix, err := secretKeyPrefix(project)
+	if err != nil {
+		return err
+	}
+	err = storeSecrets(polarisHome, specParameters, component.Parameters, fmt.Sprintf("%s/%s", keyPrefix, component.Component), false)
+	if err != nil {
+		return err
+	}
+
+	// Record the component in the project so that it can be rendered again later
+	//
+	if project.Components == nil {
+		project.Components = map[string]config.PolarisProjectComponent{}
+	}
+	project.Components[component.Component] = config.PolarisProjectComponent{
+		Scaffold:   componentName,
+		Commit:     componentScaffold.Commit,
+		Parameters: component.Parameters,
+	}
+	return SaveLocalProject(".", project)
 }
 
 func shouldExcludeFile(filePath string) bool {
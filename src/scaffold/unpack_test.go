@@ -0,0 +1,164 @@
+package scaffold
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+	"github.com/synthesis-labs/polaris-cli/src/secrets"
+)
+
+// writeFiles writes files (keyed by their slash separated path) under root
+//
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, contents := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readFile returns the contents of a file, or what went wrong reading it
+//
+func readFile(path string) string {
+	contents, err := ioutil.ReadFile(filepath.FromSlash(path))
+	if err != nil {
+		return err.Error()
+	}
+	return string(contents)
+}
+
+// inDirectory changes into a directory, returning a function which changes back
+//
+func inDirectory(t *testing.T, directory string) func() {
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(directory); err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		if err := os.Chdir(previous); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// newTestScaffold writes the files of a scaffold under root, returning the scaffold
+//
+func newTestScaffold(t *testing.T, root string, name string, parameters []config.PolarisScaffoldParameter, files map[string]string) *config.PolarisScaffold {
+	localPath := filepath.Join(root, "scaffolds", filepath.FromSlash(name))
+	writeFiles(t, localPath, files)
+	return &config.PolarisScaffold{
+		Name:      name,
+		LocalPath: localPath,
+		Spec:      config.PolarisScaffoldSpec{Parameters: parameters},
+	}
+}
+
+func TestUnpackStoresSecretsOnceUnpacked(t *testing.T) {
+	root, err := ioutil.TempDir("", "polaris-unpack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	polarisHome := filepath.Join(root, "home")
+
+	componentScaffold := newTestScaffold(t, root, "test/component",
+		[]config.PolarisScaffoldParameter{{Name: "pw", Secret: true}},
+		map[string]string{"[[ .Component ]]/values.yaml": "password: [[ .Parameters.pw ]]\n"})
+	featureScaffold := newTestScaffold(t, root, "test/feature",
+		[]config.PolarisScaffoldParameter{{Name: "token", Secret: true}},
+		map[string]string{"[[ .Component ]]/feature.yaml": "token: [[ .Parameters.token ]]\n"})
+
+	projectPath := filepath.Join(root, "project")
+	writeFiles(t, projectPath, map[string]string{"api/values.yaml": "mine\n"})
+	defer inDirectory(t, projectPath)()
+	project := &config.PolarisProject{
+		Project:    "orders",
+		ID:         "orders",
+		Parameters: map[string]string{},
+		Components: map[string]config.PolarisProjectComponent{},
+	}
+	stored := func(key string) bool {
+		free, err := secrets.FreeKey(polarisHome, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return free != key
+	}
+
+	// A component which can't be unpacked leaves no secret behind, so it can be added again
+	//
+	err = UnpackComponent(polarisHome, componentScaffold, project, map[string]string{"pw": "hunter2"}, "test/component", "api", false)
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("expected api/values.yaml to be in the way, got %v", err)
+	}
+	if stored("orders/api/pw") {
+		t.Errorf("the secret of the component which wasn't unpacked was stored")
+	}
+	if _, found := project.Components["api"]; found {
+		t.Errorf("the component which wasn't unpacked was recorded")
+	}
+
+	os.Remove(filepath.Join("api", "values.yaml"))
+	err = UnpackComponent(polarisHome, componentScaffold, project, map[string]string{"pw": "hunter2"}, "test/component", "api", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := readFile("api/values.yaml"); got != "password: hunter2\n" {
+		t.Errorf("api/values.yaml = %q", got)
+	}
+	if got := project.Components["api"].Parameters["pw"]; got != "secret://orders/api/pw" {
+		t.Errorf("pw is recorded as %q, want secret://orders/api/pw", got)
+	}
+	if got := readFile("polaris-project.yaml"); strings.Contains(got, "hunter2") || !strings.Contains(got, "secret://orders/api/pw") {
+		t.Errorf("polaris-project.yaml doesn't hold just the reference to the secret:\n%s", got)
+	}
+
+	// The same goes for a feature pack
+	//
+	writeFiles(t, ".", map[string]string{"api/feature.yaml": "mine\n"})
+	err = AddFeature(polarisHome, featureScaffold, project, "api", map[string]string{"token": "abc"}, false)
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("expected api/feature.yaml to be in the way, got %v", err)
+	}
+	if stored("orders/api/test/feature/token") {
+		t.Errorf("the secret of the feature pack which wasn't added was stored")
+	}
+	os.Remove(filepath.Join("api", "feature.yaml"))
+	err = AddFeature(polarisHome, featureScaffold, project, "api", map[string]string{"token": "abc"}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := project.Components["api"].Features[0].Parameters["token"]; got != "secret://orders/api/test/feature/token" {
+		t.Errorf("token is recorded as %q, want secret://orders/api/test/feature/token", got)
+	}
+
+	// A component added under the name of one which has since been renamed (and so still refers to the
+	// secrets stored under its old name) gets secrets of its own
+	//
+	project.Components["web"] = project.Components["api"]
+	delete(project.Components, "api")
+	os.Rename("api", "web")
+	err = UnpackComponent(polarisHome, componentScaffold, project, map[string]string{"pw": "swordfish"}, "test/component", "api", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := project.Components["api"].Parameters["pw"]; got != "secret://orders/api/pw#2" {
+		t.Errorf("pw is recorded as %q, want secret://orders/api/pw#2", got)
+	}
+	for component, want := range map[string]string{"api": "swordfish", "web": "hunter2"} {
+		if got, _ := secrets.Resolve(polarisHome, project.Components[component].Parameters["pw"]); got != want {
+			t.Errorf("the pw of %s resolves to %q, want %q", component, got, want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package scaffold
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+	"github.com/synthesis-labs/polaris-cli/src/secrets"
+)
+
+// ProjectScaffolds holds the scaffolds of a project along with those of everything unpacked into it: the
+// components' scaffolds keyed by component name and the feature packs' keyed by FeatureKey
+//
+type ProjectScaffolds struct {
+	Project    *config.PolarisScaffold
+	Components map[string]*config.PolarisScaffold
+	Features   map[string]*config.PolarisScaffold
+}
+
+// UpgradeProject moves the project within the current directory (and all of its components and feature packs)
+// from the scaffolds it was generated from to the scaffolds as they are now, keeping their parameters. The
+// changes to files which have been edited locally are merged into them, as when the project is renamed. For
+// a dry run nothing is changed, and the report records what would be
+//
+func UpgradeProject(polarisHome string, oldScaffolds *ProjectScaffolds, newScaffolds *ProjectScaffolds, project *config.PolarisProject, dryRun bool) (*RerenderReport, error) {
+	err := checkComponentsRecorded(project)
+	if err != nil {
+		return nil, err
+	}
+	report := &RerenderReport{DryRun: dryRun}
+
+	projectValues, err := resolveProjectValues(polarisHome, project)
+	if err != nil {
+		return nil, err
+	}
+	err = rerender(oldScaffolds.Project, newScaffolds.Project, projectValues, projectValues, ".", report)
+	if err != nil {
+		return nil, err
+	}
+
+	for componentName, recorded := range project.Components {
+		oldScaffold, foundOld := oldScaffolds.Components[componentName]
+		newScaffold, foundNew := newScaffolds.Components[componentName]
+		if !foundOld || !foundNew {
+			return nil, fmt.Errorf("Component %s has not been loaded", componentName)
+		}
+
+		component, err := recordedComponentValues(project, componentName)
+		if err != nil {
+			return nil, err
+		}
+		componentValues, err := resolveComponentValues(polarisHome, component)
+		if err != nil {
+			return nil, err
+		}
+		err = rerenderComponent(polarisHome, oldScaffold, newScaffold, oldScaffolds.Features, newScaffolds.Features, recorded, componentValues, componentValues, report)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	// Everything is now as generated from the new commits
+	//
+	project.ScaffoldCommit = newScaffolds.Project.Commit
+	for componentName, recorded := range project.Components {
+		recorded.Commit = newScaffolds.Components[componentName].Commit
+		for i, feature := range recorded.Features {
+			recorded.Features[i].Commit = newScaffolds.Features[FeatureKey(feature)].Commit
+		}
+		project.Components[componentName] = recorded
+	}
+	return report, SaveLocalProject(".", project)
+}
+
+// lintParameters checks the parameters recorded for a scaffold against those the scaffold specifies
+//
+func lintParameters(polarisHome string, what string, specParameters []config.PolarisScaffoldParameter, recorded map[string]string) []string {
+	problems := []string{}
+	specified := map[string]bool{}
+	for _, parameter := range specParameters {
+		specified[parameter.Name] = true
+		if _, found := recorded[parameter.Name]; !found {
+			problems = append(problems, fmt.Sprintf("%s: parameter %s is not recorded", what, parameter.Name))
+		}
+	}
+
+	names := []string{}
+	for name := range recorded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !specified[name] {
+			problems = append(problems, fmt.Sprintf("%s: parameter %s is not specified by the scaffold", what, name))
+		}
+		if _, err := secrets.Resolve(polarisHome, recorded[name]); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: parameter %s can't be resolved: %s", what, name, err))
+		}
+	}
+	return problems
+}
+
+// lintScaffold checks that the commit a scaffold was generated from is recorded, and that the scaffold still
+// renders with the recorded values
+//
+func lintScaffold(what string, scaffold *config.PolarisScaffold, commit string, values interface{}) []string {
+	problems := []string{}
+	if commit == "" && scaffold.Commit != "" {
+		problems = append(problems, fmt.Sprintf("%s: the commit of %s it was generated from is not recorded", what, scaffold.Name))
+	}
+	if _, err := renderPlan(scaffold, values, "."); err != nil {
+		problems = append(problems, fmt.Sprintf("%s: %s doesn't render: %s", what, scaffold.Name, err))
+	}
+	return problems
+}
+
+// LintProject checks the project within the current directory (and everything unpacked into it) against the
+// scaffolds it was generated from, returning the problems found
+//
+func LintProject(polarisHome string, scaffolds *ProjectScaffolds, project *config.PolarisProject) []string {
+	err := checkComponentsRecorded(project)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	problems := lintParameters(polarisHome, "project", scaffolds.Project.Spec.Parameters, project.Parameters)
+	projectValues, err := resolveProjectValues(polarisHome, project)
+	if err != nil {
+		return problems
+	}
+	problems = append(problems, lintScaffold("project", scaffolds.Project, project.ScaffoldCommit, projectValues)...)
+
+	componentNames := []string{}
+	for componentName := range project.Components {
+		componentNames = append(componentNames, componentName)
+	}
+	sort.Strings(componentNames)
+
+	for _, componentName := range componentNames {
+		recorded := project.Components[componentName]
+		what := fmt.Sprintf("component %s", componentName)
+		componentScaffold, found := scaffolds.Components[componentName]
+		if !found {
+			problems = append(problems, fmt.Sprintf("%s: the scaffold has not been loaded", what))
+			continue
+		}
+
+		problems = append(problems, lintParameters(polarisHome, what, componentScaffold.Spec.Parameters, recorded.Parameters)...)
+		component, err := recordedComponentValues(project, componentName)
+		if err != nil {
+			return append(problems, err.Error())
+		}
+		componentValues, err := resolveComponentValues(polarisHome, component)
+		if err != nil {
+			continue
+		}
+		problems = append(problems, lintScaffold(what, componentScaffold, recorded.Commit, componentValues)...)
+
+		for _, feature := range recorded.Features {
+			what := fmt.Sprintf("feature %s of component %s", featureName(feature.Scaffold), componentName)
+			featureScaffold, found := scaffolds.Features[FeatureKey(feature)]
+			if !found {
+				problems = append(problems, fmt.Sprintf("%s: the scaffold has not been loaded", what))
+				continue
+			}
+
+			problems = append(problems, lintParameters(polarisHome, what, featureScaffold.Spec.Parameters, feature.Parameters)...)
+			featureValues, err := resolveFeatureValues(polarisHome, componentValues, feature)
+			if err != nil {
+				continue
+			}
+			problems = append(problems, lintScaffold(what, featureScaffold, feature.Commit, featureValues)...)
+		}
+	}
+
+	return problems
+}
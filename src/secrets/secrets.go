@@ -0,0 +1,356 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// secretPrefix references a value held in the encrypted store under POLARIS_HOME
+	//
+	secretPrefix = "secret://"
+
+	// envPrefix references a value held in an environment variable
+	//
+	envPrefix = "env://"
+
+	// passphraseEnv is the environment variable holding the passphrase which protects the store.
+	// Without one the store is encrypted with a key kept alongside it, which only obfuscates it
+	//
+	passphraseEnv = "POLARIS_SECRETS_PASSPHRASE"
+)
+
+// IsReference checks whether a parameter value is a reference rather than the value itself
+//
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, secretPrefix) || strings.HasPrefix(value, envPrefix)
+}
+
+// Resolve returns the value a parameter refers to (or the value itself if it isn't a reference)
+//
+func Resolve(polarisHome string, value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, envPrefix):
+		name := strings.TrimPrefix(value, envPrefix)
+		resolved, found := os.LookupEnv(name)
+		if !found {
+			return "", fmt.Errorf("Environment variable %s is not set", name)
+		}
+		return resolved, nil
+
+	case strings.HasPrefix(value, secretPrefix):
+		key := strings.TrimPrefix(value, secretPrefix)
+		store, err := load(polarisHome)
+		if err != nil {
+			return "", err
+		}
+		resolved, found := store[key]
+		if !found {
+			return "", fmt.Errorf("Secret %s not found in the local secret store", key)
+		}
+		return resolved, nil
+	}
+
+	return value, nil
+}
+
+// ResolveAll returns a copy of the parameters with all references resolved
+//
+func ResolveAll(polarisHome string, parameters map[string]string) (map[string]string, error) {
+	resolved := map[string]string{}
+	for name, value := range parameters {
+		v, err := Resolve(polarisHome, value)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to resolve parameter %s: %s", name, err)
+		}
+		resolved[name] = v
+	}
+	return resolved, nil
+}
+
+// Store saves a value in the encrypted store and returns the reference to record in its place. A key
+// which is already in the store is never overwritten, as something may still refer to it
+//
+func Store(polarisHome string, key string, value string) (string, error) {
+	store, err := load(polarisHome)
+	if err != nil {
+		return "", err
+	}
+	if _, found := store[key]; found {
+		return "", fmt.Errorf("Secret %s is already in the local secret store", key)
+	}
+	store[key] = value
+	err = save(polarisHome, store)
+	if err != nil {
+		return "", err
+	}
+	return secretPrefix + key, nil
+}
+
+// FreeKey returns the key, or if it is already in the store the first of key#2, key#3 and so on which
+// isn't, for a new value to be stored under without disturbing what is already there
+//
+func FreeKey(polarisHome string, key string) (string, error) {
+	store, err := load(polarisHome)
+	if err != nil {
+		return "", err
+	}
+	free := key
+	for n := 2; ; n++ {
+		if _, found := store[free]; !found {
+			return free, nil
+		}
+		free = fmt.Sprintf("%s#%d", key, n)
+	}
+}
+
+// Replace saves a value in the encrypted store whether or not the key is already there, returning the
+// reference to record in its place
+//
+func Replace(polarisHome string, key string, value string) (string, error) {
+	store, err := load(polarisHome)
+	if err != nil {
+		return "", err
+	}
+	store[key] = value
+	err = save(polarisHome, store)
+	if err != nil {
+		return "", err
+	}
+	return secretPrefix + key, nil
+}
+
+// Generate generates a new value for a parameter, being either a random password or a uuid
+//
+func Generate(kind string) (string, error) {
+	switch kind {
+	case "password":
+		const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+		password := make([]byte, 24)
+		for i := range password {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+			if err != nil {
+				return "", err
+			}
+			password[i] = alphabet[n.Int64()]
+		}
+		return string(password), nil
+
+	case "uuid":
+		uuid := make([]byte, 16)
+		_, err := rand.Read(uuid)
+		if err != nil {
+			return "", err
+		}
+		uuid[6] = (uuid[6] & 0x0f) | 0x40
+		uuid[8] = (uuid[8] & 0x3f) | 0x80
+		return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:]), nil
+	}
+
+	return "", fmt.Errorf("Unknown generator %s (expected password or uuid)", kind)
+}
+
+// storePath returns where the store is kept, encrypted with the key alongside it
+//
+func storePath(polarisHome string) string {
+	return filepath.Join(polarisHome, "secrets", "store")
+}
+
+// protectedStorePath returns where the store is kept when it is protected by a passphrase
+//
+func protectedStorePath(polarisHome string) string {
+	return filepath.Join(polarisHome, "secrets", "store.pgp")
+}
+
+// key returns the key of the local store, creating it the first time. As the key is kept next to the
+// store, anyone who can read the store can decrypt it: it only keeps the values from being read by accident
+//
+func key(polarisHome string) ([]byte, error) {
+	keyPath := filepath.Join(polarisHome, "secrets", "key")
+	k, err := ioutil.ReadFile(keyPath)
+	if err == nil {
+		return k, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	k = make([]byte, 32)
+	_, err = rand.Read(k)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(filepath.Dir(keyPath), 0700)
+	if err != nil {
+		return nil, err
+	}
+	err = ioutil.WriteFile(keyPath, k, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func newCipher(polarisHome string) (cipher.AEAD, error) {
+	k, err := key(polarisHome)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// passphrase returns the passphrase protecting the store, if one is set
+//
+func passphrase() (string, bool) {
+	value := os.Getenv(passphraseEnv)
+	return value, value != ""
+}
+
+// load decrypts the local store
+//
+func load(polarisHome string) (map[string]string, error) {
+	store := map[string]string{}
+
+	plain, err := readStore(polarisHome)
+	if err != nil || plain == nil {
+		return store, err
+	}
+
+	err = yaml.Unmarshal(plain, &store)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// readStore decrypts the local store, with the passphrase if it is protected by one (nil if there is no store yet)
+//
+func readStore(polarisHome string) ([]byte, error) {
+	data, err := ioutil.ReadFile(protectedStorePath(polarisHome))
+	if err == nil {
+		return decryptWithPassphrase(data)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	data, err = ioutil.ReadFile(storePath(polarisHome))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newCipher(polarisHome)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("Local secret store is corrupt")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decrypt local secret store: %s", err)
+	}
+	return plain, nil
+}
+
+// decryptWithPassphrase decrypts a store protected by a passphrase
+//
+func decryptWithPassphrase(data []byte) ([]byte, error) {
+	secret, found := passphrase()
+	if !found {
+		return nil, fmt.Errorf("The local secret store is protected by a passphrase, set it in %s", passphraseEnv)
+	}
+
+	// The passphrase is only offered once, as it is asked for again when it is wrong
+	//
+	offered := false
+	message, err := openpgp.ReadMessage(bytes.NewReader(data), openpgp.EntityList{}, func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
+		if offered {
+			return nil, errors.New("the passphrase is wrong")
+		}
+		offered = true
+		return []byte(secret), nil
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decrypt local secret store: %s", err)
+	}
+	plain, err := ioutil.ReadAll(message.UnverifiedBody)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decrypt local secret store: %s", err)
+	}
+	return plain, nil
+}
+
+// save encrypts the local store. Once a passphrase is set the store is protected by it, and the key
+// which used to encrypt it is removed
+//
+func save(polarisHome string, store map[string]string) error {
+	plain, err := yaml.Marshal(store)
+	if err != nil {
+		return err
+	}
+
+	if secret, found := passphrase(); found {
+		var buff bytes.Buffer
+		writer, err := openpgp.SymmetricallyEncrypt(&buff, []byte(secret), nil, &packet.Config{DefaultCipher: packet.CipherAES256, S2KCount: 65011712})
+		if err != nil {
+			return err
+		}
+		_, err = writer.Write(plain)
+		if err != nil {
+			return err
+		}
+		err = writer.Close()
+		if err != nil {
+			return err
+		}
+
+		err = os.MkdirAll(filepath.Dir(protectedStorePath(polarisHome)), 0700)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(protectedStorePath(polarisHome), buff.Bytes(), 0600)
+		if err != nil {
+			return err
+		}
+		for _, obsolete := range []string{storePath(polarisHome), filepath.Join(polarisHome, "secrets", "key")} {
+			if err := os.Remove(obsolete); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if _, err := os.Stat(protectedStorePath(polarisHome)); err == nil {
+		return fmt.Errorf("The local secret store is protected by a passphrase, set it in %s", passphraseEnv)
+	}
+
+	gcm, err := newCipher(polarisHome)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(storePath(polarisHome), gcm.Seal(nonce, nonce, plain, nil), 0600)
+}
@@ -0,0 +1,107 @@
+package secrets
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStoreNeverOverwrites(t *testing.T) {
+	polarisHome, err := ioutil.TempDir("", "polaris-secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(polarisHome)
+
+	reference, err := Store(polarisHome, "project/password", "first")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if reference != "secret://project/password" {
+		t.Errorf("got reference %s", reference)
+	}
+
+	_, err = Store(polarisHome, "project/password", "second")
+	if err == nil || !strings.Contains(err.Error(), "already in the local secret store") {
+		t.Fatalf("expected the secret not to be overwritten, got %v", err)
+	}
+	if value, _ := Resolve(polarisHome, reference); value != "first" {
+		t.Errorf("got %s, want first", value)
+	}
+
+	_, err = Replace(polarisHome, "project/password", "second")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if value, _ := Resolve(polarisHome, reference); value != "second" {
+		t.Errorf("got %s, want second", value)
+	}
+}
+
+func TestPassphrase(t *testing.T) {
+	polarisHome, err := ioutil.TempDir("", "polaris-secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(polarisHome)
+	defer os.Unsetenv(passphraseEnv)
+
+	// A store encrypted with the key alongside it moves to the passphrase, and the key goes
+	//
+	os.Unsetenv(passphraseEnv)
+	reference, err := Store(polarisHome, "project/password", "hunter2")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	os.Setenv(passphraseEnv, "correct horse")
+	_, err = Store(polarisHome, "project/token", "abc")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, obsolete := range []string{"store", "key"} {
+		if _, err := os.Stat(filepath.Join(polarisHome, "secrets", obsolete)); !os.IsNotExist(err) {
+			t.Errorf("expected secrets/%s to be removed", obsolete)
+		}
+	}
+	if value, err := Resolve(polarisHome, reference); err != nil || value != "hunter2" {
+		t.Errorf("got %s (%v), want hunter2", value, err)
+	}
+
+	os.Setenv(passphraseEnv, "wrong")
+	if _, err := Resolve(polarisHome, reference); err == nil {
+		t.Error("expected the wrong passphrase to fail")
+	}
+	os.Unsetenv(passphraseEnv)
+	if _, err := Resolve(polarisHome, reference); err == nil || !strings.Contains(err.Error(), passphraseEnv) {
+		t.Errorf("expected to be asked for the passphrase, got %v", err)
+	}
+	if _, err := Store(polarisHome, "project/other", "x"); err == nil {
+		t.Error("expected the store not to be written without the passphrase")
+	}
+}
+
+func TestFreeKey(t *testing.T) {
+	polarisHome, err := ioutil.TempDir("", "polaris-secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(polarisHome)
+
+	for _, want := range []string{"project/password", "project/password#2", "project/password#3"} {
+		key, err := FreeKey(polarisHome, "project/password")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if key != want {
+			t.Errorf("got %s, want %s", key, want)
+		}
+		if _, err := Store(polarisHome, key, want); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if value, _ := Resolve(polarisHome, "secret://project/password"); value != "project/password" {
+		t.Errorf("the first secret was changed to %s", value)
+	}
+}
@@ -0,0 +1,158 @@
+package workspace
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+	"github.com/synthesis-labs/polaris-cli/src/options"
+	"github.com/synthesis-labs/polaris-cli/src/scaffold"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ProjectResult holds the outcome of running an operation against a single project of the workspace
+//
+type ProjectResult struct {
+	Directory string
+	Project   string
+	Err       error
+}
+
+// GetLocalWorkspace reads the polaris-workspace.yaml in the given directory. If there is no
+// workspace file then a workspace which discovers its projects is returned instead
+//
+func GetLocalWorkspace(directory string) (*config.PolarisWorkspace, error) {
+	workspaceData, err := ioutil.ReadFile(filepath.Join(directory, "polaris-workspace.yaml"))
+	if os.IsNotExist(err) {
+		return &config.PolarisWorkspace{Discover: true}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	workspace := config.PolarisWorkspace{}
+	err = yaml.Unmarshal(workspaceData, &workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	return &workspace, nil
+}
+
+// FindProjects returns the (sorted) project directories within the workspace, being those listed
+// explicitly plus those discovered by searching for polaris-project.yaml files
+//
+func FindProjects(directory string, workspace *config.PolarisWorkspace) ([]string, error) {
+	found := map[string]bool{}
+
+	for _, project := range workspace.Projects {
+		projectDirectory := filepath.Clean(filepath.Join(directory, project))
+		if _, err := os.Stat(filepath.Join(projectDirectory, "polaris-project.yaml")); err != nil {
+			return nil, fmt.Errorf("Workspace project %s is not a polaris project: %s", project, err)
+		}
+		found[projectDirectory] = true
+	}
+
+	if workspace.Discover {
+		err := filepath.Walk(directory, func(filename string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			// Don't descend into hidden folders (.git etc)
+			//
+			if info.IsDir() && filename != directory && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+
+			if !info.IsDir() && info.Name() == "polaris-project.yaml" {
+				found[filepath.Dir(filename)] = true
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := []string{}
+	for projectDirectory := range found {
+		result = append(result, projectDirectory)
+	}
+	sort.Strings(result)
+
+	return result, nil
+}
+
+// ForEachProject runs the action against every project directory, carrying on past failures so
+// that all the results can be reported together
+//
+func ForEachProject(directories []string, action func(directory string, project *config.PolarisProject) error) []ProjectResult {
+	results := []ProjectResult{}
+
+	for _, directory := range directories {
+		result := ProjectResult{Directory: directory}
+
+		project, err := scaffold.GetLocalProjectIn(directory, "project")
+		if err != nil {
+			result.Err = err
+			results = append(results, result)
+			continue
+		}
+		result.Project = project.Project
+
+		if options.IsVerbose() {
+			fmt.Println("Workspace project", project.Project, "in", directory)
+		}
+
+		result.Err = action(directory, project)
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// InDirectory runs an action from within a project directory, as the operations on a project work on the
+// project within the current directory
+//
+func InDirectory(directory string, action func() error) error {
+	current, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	err = os.Chdir(directory)
+	if err != nil {
+		return err
+	}
+	defer os.Chdir(current)
+
+	return action()
+}
+
+// PrintReport prints the aggregated results and returns an error if any of the projects failed
+//
+func PrintReport(results []ProjectResult) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, "PROJECT\tDIRECTORY\tRESULT\t")
+
+	failed := 0
+	for _, result := range results {
+		outcome := "ok"
+		if result.Err != nil {
+			outcome = fmt.Sprintf("failed: %s", result.Err)
+			failed++
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t\n", result.Project, result.Directory, outcome)
+	}
+	writer.Flush()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d projects failed", failed, len(results))
+	}
+	return nil
+}
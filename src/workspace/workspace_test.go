@@ -0,0 +1,206 @@
+package workspace
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/synthesis-labs/polaris-cli/src/config"
+)
+
+// newTestWorkspace writes a polaris-project.yaml for each of the projects (keyed by their slash separated
+// directory) under a new directory, returning it
+//
+func newTestWorkspace(t *testing.T, projects map[string]string) string {
+	root, err := ioutil.TempDir("", "polaris-workspace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for directory, name := range projects {
+		projectDirectory := filepath.Join(root, filepath.FromSlash(directory))
+		if err := os.MkdirAll(projectDirectory, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		contents := []byte("project: " + name + "\ncomponents: {}\n")
+		if err := ioutil.WriteFile(filepath.Join(projectDirectory, "polaris-project.yaml"), contents, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestFindProjects(t *testing.T) {
+	root := newTestWorkspace(t, map[string]string{
+		"orders":               "orders",
+		"orders/tools/loader":  "loader",
+		"team/billing":         "billing",
+		".git/modules/vendor":  "hidden",
+		"services/.cache/copy": "hidden",
+	})
+	defer os.RemoveAll(root)
+	if err := os.MkdirAll(filepath.Join(root, "empty"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		workspace config.PolarisWorkspace
+		want      []string
+		wantErr   string
+	}{
+		{
+			name:      "discovered, including nested projects but not hidden directories",
+			workspace: config.PolarisWorkspace{Discover: true},
+			want:      []string{"orders", "orders/tools/loader", "team/billing"},
+		},
+		{
+			name:      "listed",
+			workspace: config.PolarisWorkspace{Projects: []string{"team/billing", "./orders/"}},
+			want:      []string{"orders", "team/billing"},
+		},
+		{
+			name:      "listed in a hidden directory",
+			workspace: config.PolarisWorkspace{Projects: []string{".git/modules/vendor"}},
+			want:      []string{".git/modules/vendor"},
+		},
+		{
+			name:      "listed and discovered",
+			workspace: config.PolarisWorkspace{Projects: []string{"orders", "services/.cache/copy"}, Discover: true},
+			want:      []string{"orders", "orders/tools/loader", "services/.cache/copy", "team/billing"},
+		},
+		{
+			name:      "listed directory which isn't a project",
+			workspace: config.PolarisWorkspace{Projects: []string{"orders", "empty"}},
+			wantErr:   "Workspace project empty is not a polaris project",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := FindProjects(root, &test.workspace)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			want := []string{}
+			for _, directory := range test.want {
+				want = append(want, filepath.Join(root, filepath.FromSlash(directory)))
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("FindProjects = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestForEachProject(t *testing.T) {
+	root := newTestWorkspace(t, map[string]string{"orders": "orders", "billing": "billing", "stock": "stock"})
+	defer os.RemoveAll(root)
+	directories := []string{
+		filepath.Join(root, "billing"),
+		filepath.Join(root, "missing"),
+		filepath.Join(root, "orders"),
+		filepath.Join(root, "stock"),
+	}
+
+	start, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every project is run in its own directory, carrying on past those which fail
+	//
+	ran := []string{}
+	results := ForEachProject(directories, func(directory string, project *config.PolarisProject) error {
+		return InDirectory(directory, func() error {
+			current, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+			ran = append(ran, filepath.Base(current))
+			if project.Project == "orders" {
+				return errors.New("orders failed")
+			}
+			return nil
+		})
+	})
+
+	if current, _ := os.Getwd(); current != start {
+		t.Errorf("left in %s, want %s", current, start)
+	}
+	if want := []string{"billing", "orders", "stock"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran in %v, want %v", ran, want)
+	}
+
+	if len(results) != len(directories) {
+		t.Fatalf("got %d results, want %d", len(results), len(directories))
+	}
+	wants := []struct {
+		project string
+		err     string
+	}{
+		{"billing", ""},
+		{"", "no such file or directory"},
+		{"orders", "orders failed"},
+		{"stock", ""},
+	}
+	for i, want := range wants {
+		result := results[i]
+		if result.Directory != directories[i] || result.Project != want.project {
+			t.Errorf("result %d is %s in %s, want %s in %s", i, result.Project, result.Directory, want.project, directories[i])
+		}
+		if want.err == "" && result.Err != nil {
+			t.Errorf("unexpected error for %s: %s", want.project, result.Err)
+		} else if want.err != "" && (result.Err == nil || !strings.Contains(result.Err.Error(), want.err)) {
+			t.Errorf("expected an error containing %q for %s, got %v", want.err, directories[i], result.Err)
+		}
+	}
+
+	err = PrintReport(results)
+	if err == nil || err.Error() != "2 of 4 projects failed" {
+		t.Errorf("expected the report to fail with 2 of 4 projects failed, got %v", err)
+	}
+}
+
+func TestInDirectory(t *testing.T) {
+	root := newTestWorkspace(t, map[string]string{"orders": "orders"})
+	defer os.RemoveAll(root)
+	start, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = InDirectory(filepath.Join(root, "orders"), func() error {
+		return errors.New("failed")
+	})
+	if err == nil || err.Error() != "failed" {
+		t.Errorf("expected the action's error, got %v", err)
+	}
+	if current, _ := os.Getwd(); current != start {
+		t.Errorf("left in %s after the action failed, want %s", current, start)
+	}
+
+	err = InDirectory(filepath.Join(root, "missing"), func() error {
+		t.Errorf("the action was run")
+		return nil
+	})
+	if err == nil {
+		t.Errorf("expected changing into a missing directory to fail")
+	}
+	if current, _ := os.Getwd(); current != start {
+		t.Errorf("left in %s, want %s", current, start)
+	}
+}